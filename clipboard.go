@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// copyToClipboard copies text to the system clipboard.
+//
+// The OSC 52 escape sequence is written to the terminal, which works over ssh
+// and inside tmux or screen if the terminal supports it. When running locally
+// a clipboard tool (wl-copy, xclip, xsel or pbcopy) is used in addition, as
+// some terminals ignore OSC 52. git config gb.clipboard restricts this to
+// either "osc52" or the name of a tool.
+func copyToClipboard(dir, text string) error {
+	method := gitConfig(dir, "gb.clipboard")
+	switch method {
+	case "osc52":
+		return copyOSC52(text)
+	case "":
+	default:
+		return copyTool(method, text)
+	}
+
+	errOSC52 := copyOSC52(text)
+	if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		return errOSC52
+	}
+
+	tool := clipboardTool()
+	if tool == "" {
+		return errOSC52
+	}
+	errTool := copyTool(tool, text)
+	if errOSC52 != nil && errTool != nil {
+		return errors.Join(errOSC52, errTool)
+	}
+	return nil
+}
+
+func copyOSC52(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open tty for osc52 err=%w", err)
+	}
+	defer tty.Close()
+
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = "\x1bP" + seq + "\x1b\\"
+	}
+
+	_, err = tty.WriteString(seq)
+	return err
+}
+
+// clipboardTool returns the first available clipboard tool for the current
+// session, "" if there is none.
+func clipboardTool() string {
+	candidates := []string{}
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = append(candidates, "pbcopy")
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		candidates = append(candidates, "wl-copy", "xclip", "xsel")
+	case os.Getenv("DISPLAY") != "":
+		candidates = append(candidates, "xclip", "xsel")
+	}
+
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c); err == nil {
+			return c
+		}
+	}
+	return ""
+}
+
+func copyTool(tool, text string) error {
+	var args []string
+	switch tool {
+	case "xclip":
+		args = []string{"-selection", "clipboard"}
+	case "xsel":
+		args = []string{"--clipboard", "--input"}
+	}
+
+	// output isn't captured as xclip keeps running in the background to
+	// serve the selection, holding on to inherited pipes.
+	cmd := exec.Command(tool, args...)
+	cmd.Stdin = strings.NewReader(text)
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s failed err=%w", tool, err)
+	}
+	return nil
+}
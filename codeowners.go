@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// codeownersLocations are where GitHub and GitLab look for CODEOWNERS,
+// relative to the repository root.
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeownersRule is a pattern and the owners of the files it matches.
+type codeownersRule struct {
+	// line is the 1-based line of the rule in the CODEOWNERS file.
+	line    int
+	section string
+	pattern string
+	owners  []string
+	rx      *regexp.Regexp
+}
+
+type codeowners struct {
+	path  string
+	rules []codeownersRule
+}
+
+// findCodeowners reads the CODEOWNERS file of the repository at root, it
+// returns nil if there is none.
+func findCodeowners(root string) (*codeowners, error) {
+	for _, loc := range codeownersLocations {
+		fh, err := os.Open(filepath.Join(root, filepath.FromSlash(loc)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer fh.Close()
+		return parseCodeowners(loc, fh)
+	}
+	return nil, nil
+}
+
+var rxCodeownersSection = regexp.MustCompile(`^\^?\[([^\]]+)\](?:\[\d+\])?\s*(.*)$`)
+
+// parseCodeowners parses the GitHub or GitLab CODEOWNERS syntax, including
+// GitLab's sections and their default owners.
+func parseCodeowners(path string, r io.Reader) (*codeowners, error) {
+	res := &codeowners{path: path}
+	section := ""
+	defaults := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for nr := 1; scanner.Scan(); nr++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := rxCodeownersSection.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			defaults = codeownersFields(m[2])
+			continue
+		}
+
+		fields := codeownersFields(line)
+		if len(fields) == 0 {
+			messages.add(levelWarn, "skipping line %d of %s without pattern", nr, path)
+			continue
+		}
+		pattern, owners := fields[0], fields[1:]
+		if len(owners) == 0 && section != "" {
+			owners = defaults
+		}
+		rx, err := codeownersRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %#v on line %d of %s err=%w", pattern, nr, path, err)
+		}
+		res.rules = append(res.rules, codeownersRule{line: nr, section: section, pattern: pattern, owners: owners, rx: rx})
+	}
+	return res, scanner.Err()
+}
+
+// codeownersFields splits line at whitespace that isn't escaped and drops
+// trailing comments.
+func codeownersFields(line string) []string {
+	res := []string{}
+	var b strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '#':
+			if b.Len() > 0 {
+				res = append(res, b.String())
+			}
+			return res
+		case r == ' ' || r == '\t':
+			if b.Len() > 0 {
+				res = append(res, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		res = append(res, b.String())
+	}
+	return res
+}
+
+// codeownersRegexp converts a gitignore style pattern to a regular expression
+// matching paths relative to the repository root. Patterns whose last segment
+// has no wildcard match the files within matching directories, e.g. docs/*
+// matches docs/a.md but not docs/b/c.md. Patterns without a slash other than
+// a trailing one match at any depth, "/" matches all files.
+func codeownersRegexp(pattern string) (*regexp.Regexp, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(p, "/") || pattern == "/"
+	p = strings.TrimPrefix(p, "/")
+	wildcard := strings.ContainsAny(p[strings.LastIndex(p, "/")+1:], "*?")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i += 1
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	switch {
+	case p == "":
+		// "/" is the repository root.
+		b.WriteString(".*$")
+	case dirOnly:
+		b.WriteString("/.*$")
+	case wildcard:
+		b.WriteString("$")
+	default:
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(b.String())
+}
+
+// match returns the rules that apply to path, relative to the repository
+// root: the last matching rule of each section.
+func (co *codeowners) match(path string) []codeownersRule {
+	last := map[string]codeownersRule{}
+	sections := []string{}
+	for _, r := range co.rules {
+		if !r.rx.MatchString(path) {
+			continue
+		}
+		if _, ok := last[r.section]; !ok {
+			sections = append(sections, r.section)
+		}
+		last[r.section] = r
+	}
+
+	res := []codeownersRule{}
+	for _, s := range sections {
+		res = append(res, last[s])
+	}
+	return res
+}
+
+// isTeam reports whether owner is a team, whose members can't be known
+// without the forge's API.
+func isTeam(owner string) bool {
+	return strings.HasPrefix(owner, "@") && strings.Contains(owner, "/")
+}
+
+// ownerMatches reports whether owner, an @username or email, is a, matching
+// usernames against the local part of the author's emails, including GitHub's
+// noreply addresses, and against their names without spaces.
+func ownerMatches(owner string, a *author) bool {
+	owner = strings.ToLower(owner)
+	user, isUser := strings.CutPrefix(owner, "@")
+	for _, id := range a.identities {
+		email := strings.ToLower(id.email)
+		if !isUser {
+			if email == owner {
+				return true
+			}
+			continue
+		}
+
+		local, _, _ := strings.Cut(email, "@")
+		if _, noreply, ok := strings.Cut(local, "+"); ok && strings.HasSuffix(email, "users.noreply.github.com") {
+			local = noreply
+		}
+		if local == user || strings.ReplaceAll(strings.ToLower(id.name), " ", "") == user {
+			return true
+		}
+	}
+	return false
+}
+
+// ownershipCheck contrasts the declared owners of a file with the authors of
+// its surviving lines.
+type ownershipCheck struct {
+	rules  []codeownersRule
+	owners []string
+	// ownerLines are the lines of each owner that isn't a team.
+	ownerLines map[string]int
+	// ownedLines are the lines by any of the owners.
+	ownedLines int
+	lines      int
+	teams      []string
+	// flagged is set if the owners that aren't teams have fewer than the
+	// threshold percentage of the lines.
+	flagged bool
+}
+
+func checkOwnership(co *codeowners, data *blameData, threshold float64) ownershipCheck {
+	res := ownershipCheck{rules: co.match(data.path), ownerLines: map[string]int{}}
+	seen := map[string]bool{}
+	for _, r := range res.rules {
+		for _, o := range r.owners {
+			if seen[o] {
+				continue
+			}
+			seen[o] = true
+			res.owners = append(res.owners, o)
+			if isTeam(o) {
+				res.teams = append(res.teams, o)
+			}
+		}
+	}
+
+	for _, cm := range data.lineCommits {
+		if cm.sha == uncommittedSHA {
+			continue
+		}
+		res.lines += 1
+		owned := false
+		for _, o := range res.owners {
+			if !isTeam(o) && ownerMatches(o, cm.author) {
+				res.ownerLines[o] += 1
+				owned = true
+			}
+		}
+		if owned {
+			res.ownedLines += 1
+		}
+	}
+
+	individuals := len(res.owners) - len(res.teams)
+	res.flagged = individuals > 0 && res.lines > 0 && 100*float64(res.ownedLines)/float64(res.lines) < threshold
+	return res
+}
+
+// ownersThreshold is the percentage of lines below which declared owners are
+// flagged in the UI.
+const ownersThreshold = 10
+
+// showOwners compares the CODEOWNERS of the current file with its blame.
+func (c *container) showOwners() {
+	root, err := repoRoot(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get repository root err=%w", err))
+		return
+	}
+	co, err := findCodeowners(root)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to read CODEOWNERS err=%w", err))
+		return
+	}
+	if co == nil {
+		c.warn("no CODEOWNERS file")
+		return
+	}
+
+	check := checkOwnership(co, c.data, ownersThreshold)
+	grey, black, warn := tcell.GetColor("#9e9e9e"), tcell.ColorBlack, tcell.GetColor("#ff5544")
+
+	table := tview.NewTable()
+	row := 0
+	addRow := func(color tcell.Color, cells ...string) {
+		for i, text := range cells {
+			align := tview.AlignLeft
+			if i > 0 {
+				align = tview.AlignRight
+			}
+			table.SetCell(row, i, statsCell(" "+text, color, align).SetExpansion(1))
+		}
+		row += 1
+	}
+
+	addRow(grey, co.path)
+	if len(check.rules) == 0 {
+		addRow(warn, "no rule matches "+c.data.path)
+	}
+	for _, r := range check.rules {
+		rule := fmt.Sprintf("line %d: %s %s", r.line, r.pattern, strings.Join(r.owners, " "))
+		if r.section != "" {
+			rule = fmt.Sprintf("[%s] %s", r.section, rule)
+		}
+		addRow(black, tview.Escape(rule))
+	}
+
+	row += 1
+	addRow(grey, "owner", "lines", "")
+	for _, o := range check.owners {
+		if isTeam(o) {
+			addRow(black, o, "team", "")
+			continue
+		}
+		addRow(black, o, fmt.Sprint(check.ownerLines[o]), percent(check.ownerLines[o], check.lines))
+	}
+
+	row += 1
+	// like the owners' lines, authors' lines are of the committed lines.
+	addRow(grey, "author", "lines", "")
+	var uncommitted *author
+	if cm, ok := c.data.commits[uncommittedSHA]; ok {
+		uncommitted = cm.author
+	}
+	shown := 0
+	for _, st := range authorStatistics(c.data) {
+		if st.author == uncommitted || shown == 5 {
+			continue
+		}
+		shown += 1
+		addRow(st.author.color, st.author.name, fmt.Sprint(st.lines), percent(st.lines, check.lines))
+	}
+
+	switch {
+	case check.flagged:
+		row += 1
+		addRow(warn, fmt.Sprintf("owners wrote %s of the lines", percent(check.ownedLines, check.lines)))
+	case len(check.owners) > 0 && len(check.teams) == len(check.owners):
+		row += 1
+		addRow(grey, "owners are teams, their members are unknown")
+	}
+
+	table.
+		SetBorder(true).
+		SetTitle(" owners ").
+		SetTitleColor(tcell.ColorBlack.TrueColor()).
+		SetBorderColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			return event
+		}
+		c.closeModal("owners")
+		return nil
+	})
+
+	c.showModal("owners", table, 70, row+2)
+}
+
+type ownersResult struct {
+	path  string
+	check ownershipCheck
+}
+
+// runOwners implements the owners subcommand, which compares the CODEOWNERS
+// of the tracked files under the given paths with their blame.
+func runOwners(args []string, out, errOut io.Writer) error {
+	fs := flag.NewFlagSet("owners", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	threshold := fs.Float64("threshold", ownersThreshold, "flag files whose owners wrote less than this percentage of the lines")
+	flaggedOnly := fs.Bool("flagged", false, "only list flagged files")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of files to blame in parallel")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "usage: gb owners [flags] [path...]")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format %#v", *format)
+	}
+
+	root, err := repoRoot(".")
+	if err != nil {
+		return fmt.Errorf("failed to get repository root err=%w", err)
+	}
+	co, err := findCodeowners(root)
+	if err != nil {
+		return fmt.Errorf("failed to read CODEOWNERS err=%w", err)
+	}
+	if co == nil {
+		return fmt.Errorf("no CODEOWNERS file in %s", strings.Join(codeownersLocations, ", "))
+	}
+
+	files, err := listFiles(fs.Args(), "")
+	if err != nil {
+		return fmt.Errorf("failed to list files err=%w", err)
+	}
+	blames, errs := blameFiles(files, "", *workers)
+	for _, err := range errs {
+		fmt.Fprintln(errOut, err)
+	}
+
+	results := []ownersResult{}
+	for _, b := range blames {
+		check := checkOwnership(co, b.data, *threshold)
+		if *flaggedOnly && !check.flagged {
+			continue
+		}
+		results = append(results, ownersResult{path: b.path, check: check})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].path < results[j].path })
+
+	switch *format {
+	case "json":
+		return writeOwnersJSON(out, results)
+	case "csv":
+		return writeOwnersCSV(out, results)
+	}
+	return writeOwnersTable(out, results)
+}
+
+func ownedPercent(c ownershipCheck) string {
+	if len(c.owners) == 0 {
+		return "-"
+	}
+	if len(c.teams) == len(c.owners) {
+		return "teams"
+	}
+	return percent(c.ownedLines, c.lines)
+}
+
+func writeOwnersTable(w io.Writer, results []ownersResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tOWNERS\tOWNED\tLINES\tFLAG")
+	for _, r := range results {
+		owners := strings.Join(r.check.owners, " ")
+		if owners == "" {
+			owners = "-"
+		}
+		flag := ""
+		if r.check.flagged {
+			flag = "!"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", r.path, owners, ownedPercent(r.check), r.check.lines, flag)
+	}
+	return tw.Flush()
+}
+
+func writeOwnersCSV(w io.Writer, results []ownersResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "owners", "owned_lines", "lines", "flagged"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.path,
+			strings.Join(r.check.owners, " "),
+			fmt.Sprint(r.check.ownedLines),
+			fmt.Sprint(r.check.lines),
+			fmt.Sprint(r.check.flagged),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeOwnersJSON(w io.Writer, results []ownersResult) error {
+	type jsonRule struct {
+		Line    int      `json:"line"`
+		Section string   `json:"section,omitempty"`
+		Pattern string   `json:"pattern"`
+		Owners  []string `json:"owners"`
+	}
+	type jsonFile struct {
+		Path       string         `json:"path"`
+		Rules      []jsonRule     `json:"rules"`
+		Owners     []string       `json:"owners"`
+		OwnerLines map[string]int `json:"owner_lines"`
+		OwnedLines int            `json:"owned_lines"`
+		Lines      int            `json:"lines"`
+		Flagged    bool           `json:"flagged"`
+	}
+
+	out := []jsonFile{}
+	for _, r := range results {
+		f := jsonFile{
+			Path:       r.path,
+			Rules:      []jsonRule{},
+			Owners:     append([]string{}, r.check.owners...),
+			OwnerLines: r.check.ownerLines,
+			OwnedLines: r.check.ownedLines,
+			Lines:      r.check.lines,
+			Flagged:    r.check.flagged,
+		}
+		for _, rule := range r.check.rules {
+			f.Rules = append(f.Rules, jsonRule{Line: rule.line, Section: rule.section, Pattern: rule.pattern, Owners: rule.owners})
+		}
+		out = append(out, f)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
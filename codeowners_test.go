@@ -0,0 +1,181 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeownersRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{
+			pattern: "*",
+			matches: []string{"a.go", "docs/a.md", "docs/b/c.md"},
+		},
+		{
+			pattern: "/",
+			matches: []string{"a.go", "docs/a.md", "docs/b/c.md"},
+		},
+		{
+			pattern: "/README.md",
+			matches: []string{"README.md"},
+			misses:  []string{"web/README.md"},
+		},
+		{
+			pattern: "*.js",
+			matches: []string{"a.js", "web/a.js"},
+			misses:  []string{"a.jsx", "a.js.map"},
+		},
+		{
+			pattern: "docs/*",
+			matches: []string{"docs/a.md", "docs/.keep"},
+			misses:  []string{"docs/b/c.md", "docs", "web/docs/a.md"},
+		},
+		{
+			pattern: "/docs/",
+			matches: []string{"docs/a.md", "docs/b/c.md"},
+			misses:  []string{"docs", "web/docs/a.md", "docsite/a.md"},
+		},
+		{
+			pattern: "docs/",
+			matches: []string{"docs/a.md", "web/docs/a.md"},
+			misses:  []string{"docs"},
+		},
+		{
+			pattern: "**/logs",
+			matches: []string{"logs", "logs/a.log", "build/logs/a.log", "a/b/logs/c/d.log"},
+			misses:  []string{"logs.txt", "build/mylogs/a.log"},
+		},
+		{
+			pattern: "apps/",
+			matches: []string{"apps/a.go", "apps/b/c.go", "web/apps/a.go"},
+			misses:  []string{"apps", "myapps/a.go"},
+		},
+		{
+			pattern: "/build/logs/",
+			matches: []string{"build/logs/a.log", "build/logs/b/c.log"},
+			misses:  []string{"web/build/logs/a.log", "build/a.log"},
+		},
+		{
+			pattern: "docs/**/*.md",
+			matches: []string{"docs/a.md", "docs/b/c.md"},
+			misses:  []string{"docs/a.go", "web/docs/a.md"},
+		},
+		{
+			pattern: "README.md",
+			matches: []string{"README.md", "web/README.md"},
+			misses:  []string{"README.mdx", "READMExmd"},
+		},
+	}
+	for _, tt := range tests {
+		rx, err := codeownersRegexp(tt.pattern)
+		if err != nil {
+			t.Fatalf("codeownersRegexp(%#v) failed err=%v", tt.pattern, err)
+		}
+		for _, p := range tt.matches {
+			if !rx.MatchString(p) {
+				t.Errorf("%#v should match %#v (%s)", tt.pattern, p, rx)
+			}
+		}
+		for _, p := range tt.misses {
+			if rx.MatchString(p) {
+				t.Errorf("%#v shouldn't match %#v (%s)", tt.pattern, p, rx)
+			}
+		}
+	}
+}
+
+func TestParseCodeowners(t *testing.T) {
+	in := `# comment
+* @default
+\
+\
+docs/* @docs docs@example.com # trailing comment
+my\ file.txt @spaces
+
+[Backend][2] @backend-team
+/api/
+/api/legacy/ @legacy
+`
+	co, err := parseCodeowners("CODEOWNERS", strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	type rule struct {
+		line    int
+		section string
+		pattern string
+		owners  []string
+	}
+	want := []rule{
+		{line: 2, pattern: "*", owners: []string{"@default"}},
+		{line: 5, pattern: "docs/*", owners: []string{"@docs", "docs@example.com"}},
+		{line: 6, pattern: "my file.txt", owners: []string{"@spaces"}},
+		{line: 9, section: "Backend", pattern: "/api/", owners: []string{"@backend-team"}},
+		{line: 10, section: "Backend", pattern: "/api/legacy/", owners: []string{"@legacy"}},
+	}
+	got := []rule{}
+	for _, r := range co.rules {
+		got = append(got, rule{line: r.line, section: r.section, pattern: r.pattern, owners: r.owners})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		path    string
+		matches []int
+	}{
+		{path: "main.go", matches: []int{2}},
+		{path: "docs/a.md", matches: []int{5}},
+		{path: "docs/b/c.md", matches: []int{2}},
+		{path: "api/a.go", matches: []int{2, 9}},
+		{path: "api/legacy/a.go", matches: []int{2, 10}},
+	}
+	for _, tt := range tests {
+		lines := []int{}
+		for _, r := range co.match(tt.path) {
+			lines = append(lines, r.line)
+		}
+		if !reflect.DeepEqual(lines, tt.matches) {
+			t.Errorf("match(%#v) = rules on lines %v, want %v", tt.path, lines, tt.matches)
+		}
+	}
+}
+
+func TestCheckOwnership(t *testing.T) {
+	co, err := parseCodeowners("CODEOWNERS", strings.NewReader("* @alice @org/team bob@example.com\n"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b := fixtureBlame(t, "main.go",
+		blameLine{sha: strings.Repeat("a", 40), name: "Alice", email: "alice@example.com", text: "package main"},
+		blameLine{sha: strings.Repeat("c", 40), name: "Carol", email: "carol@example.com", text: ""},
+		blameLine{sha: strings.Repeat("c", 40), name: "Carol", email: "carol@example.com", text: "func main() {}"},
+		blameLine{sha: uncommittedSHA, name: "Not Committed Yet", email: "not.committed.yet", text: "// draft"},
+	)
+	b.data.path = b.path
+
+	check := checkOwnership(co, b.data, 50)
+	if check.lines != 3 || check.ownedLines != 1 {
+		t.Errorf("lines = %d owned %d, want 3 committed lines, 1 owned", check.lines, check.ownedLines)
+	}
+	wantOwners := map[string]int{"@alice": 1}
+	if !reflect.DeepEqual(check.ownerLines, wantOwners) {
+		t.Errorf("owner lines = %v, want %v", check.ownerLines, wantOwners)
+	}
+	if !reflect.DeepEqual(check.teams, []string{"@org/team"}) {
+		t.Errorf("teams = %v, want @org/team", check.teams)
+	}
+	if !check.flagged {
+		t.Errorf("owners with 33%% of the lines aren't flagged with a threshold of 50%%")
+	}
+	if checkOwnership(co, b.data, 30).flagged {
+		t.Errorf("owners with 33%% of the lines are flagged with a threshold of 30%%")
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// colorMode is how commits are colored in the info column, the log and the
+// gutter stripe.
+type colorMode int
+
+const (
+	// colorByRank shades commits from youngest to oldest of the file.
+	colorByRank colorMode = iota
+	// colorByAge shades commits by their absolute age on a log scale, so
+	// commits a day apart look alike while a year and a decade differ.
+	colorByAge
+	colorByAuthor
+	colorByCommit
+	colorModeCount
+)
+
+func (m colorMode) String() string {
+	switch m {
+	case colorByRank:
+		return "rank"
+	case colorByAge:
+		return "age"
+	case colorByAuthor:
+		return "author"
+	case colorByCommit:
+		return "commit"
+	}
+	return "unknown"
+}
+
+// parseColorMode returns the mode named s.
+func parseColorMode(s string) (colorMode, bool) {
+	for m := colorMode(0); m < colorModeCount; m++ {
+		if m.String() == s {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	uncommittedColor = "#ee6002"
+	rankYoungColor   = "#00345d"
+	rankOldColor     = "#4FC3F7"
+	ageYoungColor    = "#d84315"
+	ageOldColor      = "#90a4ae"
+)
+
+// ageScaleDays is the age at which colorByAge reaches the oldest color.
+const ageScaleDays = 10 * 365
+
+// ageFactor maps age to [0, 1] on a log scale up to ageScaleDays.
+func ageFactor(age time.Duration) float64 {
+	days := max(0, age.Hours()/24)
+	return min(1, math.Log1p(days)/math.Log1p(ageScaleDays))
+}
+
+func ageColor(age time.Duration) tcell.Color {
+	r1, g1, b1 := hexToRGB(ageYoungColor)
+	r2, g2, b2 := hexToRGB(ageOldColor)
+	r, g, b := interpolateColor(r1, g1, b1, r2, g2, b2, ageFactor(age))
+	return tcell.GetColor(rgbToHex(r, g, b))
+}
+
+// colorCommits sets the color of data's commits according to mode, ages are
+// relative to now.
+func colorCommits(data *blameData, mode colorMode, now time.Time) {
+	if cm, ok := data.commits[uncommittedSHA]; ok {
+		cm.color = tcell.GetColor(uncommittedColor)
+	}
+
+	inRange := []*commit{}
+	for _, cm := range data.sortedCommits {
+		if cm.boundary {
+			cm.color = tcell.GetColor(boundaryColor)
+			continue
+		}
+		inRange = append(inRange, cm)
+	}
+
+	switch mode {
+	case colorByRank:
+		shades := generateShades(rankYoungColor, rankOldColor, len(inRange))
+		for i, cm := range inRange {
+			cm.color = shades[i]
+		}
+	case colorByAge:
+		for _, cm := range inRange {
+			cm.color = ageColor(now.Sub(cm.authorTime))
+		}
+	case colorByAuthor:
+		for _, cm := range inRange {
+			cm.color = cm.author.color
+		}
+	case colorByCommit:
+		for _, cm := range inRange {
+			cm.color = readableColor(0.5, 0.13, hashHue(cm.sha))
+		}
+	}
+}
+
+func (c *container) cycleColorMode() {
+	c.colorMode = (c.colorMode + 1) % colorModeCount
+	c.info(fmt.Sprintf("coloring by %s", c.colorMode))
+	c.showTab()
+}
+
+func (c *container) toggleGutter() {
+	c.gutter = !c.gutter
+	c.showTab()
+}
+
+// legendSwatch is a colored block followed by label.
+func legendSwatch(color tcell.Color, label string) string {
+	return fmt.Sprintf("[%s]■[#9e9e9e] %s", color, tview.Escape(label))
+}
+
+// renderLegend explains the colors of the current coloring mode.
+func (c *container) renderLegend() {
+	if c.data == nil {
+		c.legendBar.SetText("")
+		return
+	}
+
+	items := []string{}
+	switch c.colorMode {
+	case colorByRank:
+		shades := generateShades(rankYoungColor, rankOldColor, 5)
+		items = append(items, "youngest")
+		for _, s := range shades {
+			items = append(items, fmt.Sprintf("[%s]■[#9e9e9e]", s))
+		}
+		items = append(items, "oldest commit of the file")
+	case colorByAge:
+		ages := []struct {
+			label string
+			age   time.Duration
+		}{
+			{"day", 24 * time.Hour},
+			{"week", 7 * 24 * time.Hour},
+			{"month", 30 * 24 * time.Hour},
+			{"year", 365 * 24 * time.Hour},
+			{"10 years", ageScaleDays * 24 * time.Hour},
+		}
+		for _, a := range ages {
+			items = append(items, legendSwatch(ageColor(a.age), a.label))
+		}
+	case colorByAuthor:
+		// count by identity rather than by the author value commits refer to.
+		counts := map[identity]int{}
+		authors := []*author{}
+		for _, cm := range c.data.lineCommits {
+			if cm.sha == uncommittedSHA || cm.boundary {
+				continue
+			}
+			id := identity{name: cm.author.name, email: cm.author.email}
+			if counts[id] == 0 {
+				authors = append(authors, cm.author)
+			}
+			counts[id] += 1
+		}
+		count := func(a *author) int { return counts[identity{name: a.name, email: a.email}] }
+		sort.Slice(authors, func(i, j int) bool {
+			if count(authors[i]) != count(authors[j]) {
+				return count(authors[i]) > count(authors[j])
+			}
+			return authors[i].name < authors[j].name
+		})
+		for _, a := range authors {
+			items = append(items, legendSwatch(a.color, a.name))
+		}
+	case colorByCommit:
+		for _, cm := range c.data.sortedCommits {
+			if !cm.boundary {
+				items = append(items, legendSwatch(cm.color, cm.sha[:8]))
+			}
+		}
+	}
+	if _, ok := c.data.commits[uncommittedSHA]; ok {
+		items = append(items, legendSwatch(tcell.GetColor(uncommittedColor), "uncommitted"))
+	}
+	for _, cm := range c.data.sortedCommits {
+		if cm.boundary {
+			items = append(items, legendSwatch(tcell.GetColor(boundaryColor), "outside range"))
+			break
+		}
+	}
+
+	c.legendBar.SetText(fmt.Sprintf(" [#000000]%s:[#9e9e9e] %s", c.colorMode, strings.Join(items, " ")))
+}
+
+// backgroundColor is the background text is drawn on, author and commit
+// colors keep a readable contrast to it.
+const backgroundColor = "#ffffff"
+
+// minContrast is the WCAG contrast ratio for normal text.
+const minContrast = 4.5
+
+// hashHue is a stable hue in degrees for key.
+func hashHue(key string) float64 {
+	return float64(hashString(key)%3600) / 10
+}
+
+// authorKey identifies an author across sessions, preferring the email which
+// changes less often than the spelling of a name.
+func authorKey(a *author) string {
+	if a.email != "" {
+		return strings.ToLower(a.email)
+	}
+	return strings.ToLower(a.name)
+}
+
+// assignAuthorColors gives authors colors in the perceptual OKLCH space with
+// evenly spaced hues for maximal separation. Each author has a stable
+// preferred hue, authors keep their order around the hue circle and the
+// spacing is rotated to stay close to the preferred hues, so an author's
+// color only shifts as others join the file. With many authors, lightness
+// alternates to separate neighbouring hues further.
+func assignAuthorColors(authors []*author) {
+	if len(authors) == 0 {
+		return
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		hi, hj := hashHue(authorKey(authors[i])), hashHue(authorKey(authors[j]))
+		if hi != hj {
+			return hi < hj
+		}
+		return authorKey(authors[i]) < authorKey(authors[j])
+	})
+
+	step := 360 / float64(len(authors))
+	var x, y float64
+	for i, a := range authors {
+		d := (hashHue(authorKey(a)) - float64(i)*step) * math.Pi / 180
+		x += math.Cos(d)
+		y += math.Sin(d)
+	}
+	offset := math.Atan2(y, x) * 180 / math.Pi
+
+	for i, a := range authors {
+		lightness := 0.55
+		if len(authors) > 8 && i%2 == 1 {
+			lightness = 0.42
+		}
+		a.color = readableColor(lightness, 0.15, offset+float64(i)*step)
+	}
+}
+
+// readableColor converts the OKLCH color to sRGB, reducing chroma until it's
+// in gamut and lightness until it contrasts with backgroundColor.
+func readableColor(lightness, chroma, hue float64) tcell.Color {
+	br, bg, bb := hexToRGB(backgroundColor)
+	bgLum := relativeLuminance(float64(br)/255, float64(bg)/255, float64(bb)/255)
+
+	for ; lightness > 0; lightness -= 0.02 {
+		c := chroma
+		r, g, b, ok := oklchToSRGB(lightness, c, hue)
+		for ; !ok && c > 0; c -= 0.005 {
+			r, g, b, ok = oklchToSRGB(lightness, c, hue)
+		}
+		if !ok {
+			r, g, b, _ = oklchToSRGB(lightness, 0, hue)
+		}
+
+		lum := relativeLuminance(r, g, b)
+		if contrastRatio(lum, bgLum) >= minContrast {
+			return tcell.NewRGBColor(int32(math.Round(r*255)), int32(math.Round(g*255)), int32(math.Round(b*255)))
+		}
+	}
+	return tcell.ColorBlack
+}
+
+// oklchToSRGB converts an OKLCH color to gamma encoded sRGB in [0, 1],
+// reporting whether it's in the sRGB gamut.
+func oklchToSRGB(lightness, chroma, hue float64) (float64, float64, float64, bool) {
+	h := hue * math.Pi / 180
+	a, b := chroma*math.Cos(h), chroma*math.Sin(h)
+
+	l := math.Pow(lightness+0.3963377774*a+0.2158037573*b, 3)
+	m := math.Pow(lightness-0.1055613458*a-0.0638541728*b, 3)
+	s := math.Pow(lightness-0.0894841775*a-1.2914855480*b, 3)
+
+	lin := [3]float64{
+		+4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+	}
+
+	ok := true
+	var rgb [3]float64
+	for i, v := range lin {
+		if v < -1e-4 || v > 1+1e-4 {
+			ok = false
+		}
+		v = min(1, max(0, v))
+		if v <= 0.0031308 {
+			rgb[i] = 12.92 * v
+		} else {
+			rgb[i] = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+	}
+	return rgb[0], rgb[1], rgb[2], ok
+}
+
+// relativeLuminance of a gamma encoded sRGB color as defined by WCAG.
+func relativeLuminance(r, g, b float64) float64 {
+	linear := func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+func contrastRatio(l1, l2 float64) float64 {
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
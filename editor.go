@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// editorCommand returns the command that opens file at line in the user's
+// $VISUAL or $EDITOR, falling back to vi. The editor variable may contain
+// arguments, e.g. "code -n".
+func editorCommand(file string, line int) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	fields := strings.Fields(editor)
+	name, args := fields[0], fields[1:]
+
+	switch strings.TrimSuffix(filepath.Base(name), ".exe") {
+	case "code", "code-insiders", "codium", "cursor":
+		// gui editors return immediately unless asked to wait, which would
+		// re-blame before any edits.
+		if !slices.Contains(args, "--wait") && !slices.Contains(args, "-w") {
+			args = append(args, "--wait")
+		}
+		args = append(args, "--goto", fmt.Sprintf("%s:%d", file, line))
+	case "subl", "zed":
+		if !slices.Contains(args, "--wait") && !slices.Contains(args, "-w") {
+			args = append(args, "--wait")
+		}
+		args = append(args, fmt.Sprintf("%s:%d", file, line))
+	case "hx", "helix":
+		args = append(args, fmt.Sprintf("%s:%d", file, line))
+	case "vi", "vim", "nvim", "gvim", "emacs", "emacsclient", "nano", "pico", "micro", "kak", "joe", "mg":
+		args = append(args, fmt.Sprintf("+%d", line), file)
+	default:
+		args = append(args, file)
+	}
+
+	return exec.Command(name, args...)
+}
+
+// editCurrentLine suspends the application to open the current line in the
+// user's editor and re-blames the working tree once the editor exits.
+func (c *container) editCurrentLine() {
+	if c.data == nil {
+		return
+	}
+	if _, err := os.Stat(c.filePath); err != nil {
+		c.warn(fmt.Sprintf("%s doesn't exist in the working tree", c.filePath))
+		return
+	}
+	if c.data.rev != "" {
+		messages.add(levelInfo, "editing working tree version of %s, line %v may have moved", c.filePath, c.data.fileLine(c.currentLine)+1)
+	}
+
+	cmd := editorCommand(c.filePath, c.data.fileLine(c.currentLine)+1)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	var err error
+	if !c.app.Suspend(func() { err = cmd.Run() }) {
+		c.warn("can't suspend to run the editor")
+		return
+	}
+	if err != nil {
+		c.fail(fmt.Errorf("failed to run editor %s err=%w", cmd.Path, err))
+		return
+	}
+
+	c.newRevision("", c.currentLine)
+}
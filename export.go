@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// htmlLine is a line of an exported blame with its info columns.
+type htmlLine struct {
+	Number      int
+	Code        string
+	Author      string
+	AuthorColor string
+	Date        string
+	DateColor   string
+	SHA         string
+	Color       string
+	Origin      string
+	Tooltip     string
+	CommitURL   string
+	LineURL     string
+	Boundary    bool
+}
+
+type htmlCommit struct {
+	SHA         string
+	Color       string
+	Author      string
+	AuthorColor string
+	Date        string
+	Summary     string
+	URL         string
+}
+
+type htmlPage struct {
+	Title   string
+	Details []string
+	Mode    string
+	Lines   []htmlLine
+	Commits []htmlCommit
+}
+
+var htmlTemplate = template.Must(template.New("blame").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; background: #ffffff; color: #000000; font: 13px/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+header { padding: 6px 12px; background: #e8ecf0; }
+header span { color: #9e9e9e; margin-left: 1em; }
+table { border-collapse: collapse; }
+td { padding: 0 6px; white-space: pre; vertical-align: top; tab-size: 4; }
+tr:hover td { background: #e8ecf0; }
+a { color: inherit; text-decoration: none; }
+a:hover { text-decoration: underline; }
+.stripe { padding: 0; width: 4px; }
+.origin, .nr { color: #9e9e9e; text-align: right; }
+.boundary .code { color: #bdbdbd; }
+.log { margin: 24px 12px; }
+.log td { padding: 0 12px 0 0; }
+</style>
+</head>
+<body>
+<header>{{.Title}}{{range .Details}}<span>{{.}}</span>{{end}}<span>coloring by {{.Mode}}</span></header>
+<table>
+{{- range .Lines}}
+<tr id="L{{.Number}}"{{if .Boundary}} class="boundary"{{end}} title="{{.Tooltip}}">
+<td class="stripe" style="background: {{.Color}}"></td>
+<td style="color: {{.AuthorColor}}">{{.Author}}</td>
+<td style="color: {{.DateColor}}">{{.Date}}</td>
+<td>{{if .CommitURL}}<a href="{{.CommitURL}}" style="color: {{.Color}}">{{.SHA}}</a>{{else}}<span style="color: {{.Color}}">{{.SHA}}</span>{{end}}</td>
+<td class="origin">{{.Origin}}</td>
+<td class="nr">{{if .LineURL}}<a href="{{.LineURL}}">{{.Number}}</a>{{else}}<a href="#L{{.Number}}">{{.Number}}</a>{{end}}</td>
+<td class="code">{{.Code}}</td>
+</tr>
+{{- end}}
+</table>
+<table class="log">
+{{- range .Commits}}
+<tr>
+<td>{{if .URL}}<a href="{{.URL}}" style="color: {{.Color}}">{{.SHA}}</a>{{else}}<span style="color: {{.Color}}">{{.SHA}}</span>{{end}}</td>
+<td style="color: {{.AuthorColor}}">{{.Author}}</td>
+<td style="color: #2e7d32">{{.Date}}</td>
+<td>{{.Summary}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// writeHTML writes data as a self-contained page, colored as already set by
+// colorCommits with mode. Commits and lines link to f at linkRev unless f is
+// nil or linkRev is "".
+func writeHTML(w io.Writer, data *blameData, f forge, linkRev string, mode colorMode) error {
+	page := htmlPage{Title: data.path, Mode: mode.String()}
+	switch {
+	case data.opts.reverse:
+		page.Details = append(page.Details, fmt.Sprintf("reverse %s..HEAD", shortRev(data.rev)))
+	case data.rev != "":
+		page.Details = append(page.Details, "at "+shortRev(data.rev))
+	default:
+		page.Details = append(page.Details, "working tree")
+	}
+	if data.opts.boundary != "" {
+		page.Details = append(page.Details, fmt.Sprintf("range %s..%s", data.opts.boundary, shortRev(data.rev)))
+	}
+	if data.opts.since != "" {
+		page.Details = append(page.Details, "since "+data.opts.since)
+	}
+
+	commitURL := func(cm *commit) string {
+		if f == nil || cm.sha == uncommittedSHA {
+			return ""
+		}
+		return f.commitURL(cm.sha)
+	}
+
+	for i, line := range data.lines {
+		cm := data.lineCommits[i]
+		l := htmlLine{
+			Number:      data.fileLine(i) + 1,
+			Code:        line,
+			Author:      cm.author.name,
+			AuthorColor: cm.author.color.CSS(),
+			Date:        cm.authorTime.Format("2006-01-02"),
+			DateColor:   "#2e7d32",
+			SHA:         cm.sha[:8],
+			Color:       cm.color.CSS(),
+			Tooltip:     fmt.Sprintf("%s\n%s <%s>\n%s\n%s", cm.sha, cm.author.name, cm.author.email, cm.authorTime.Format("2006-01-02 15:04:05 -0700"), cm.summary),
+			CommitURL:   commitURL(cm),
+			Boundary:    cm.boundary,
+		}
+		if cm.sha == uncommittedSHA {
+			l.Tooltip = "not committed yet"
+		}
+		if cm.boundary {
+			l.AuthorColor, l.DateColor, l.SHA = boundaryColor, boundaryColor, "^"+cm.sha[:7]
+		}
+		if o := data.origins[i]; o.path != data.path {
+			arrow := "←"
+			if data.opts.reverse {
+				arrow = "→"
+			}
+			l.Origin = fmt.Sprintf("%s %s:%d", arrow, path.Base(o.path), o.line+1)
+		}
+		if f != nil && linkRev != "" {
+			l.LineURL = f.fileURL(linkRev, data.path, l.Number, l.Number)
+		}
+		page.Lines = append(page.Lines, l)
+	}
+
+	for _, cm := range data.sortedCommits {
+		page.Commits = append(page.Commits, htmlCommit{
+			SHA:         cm.sha[:8],
+			Color:       cm.color.CSS(),
+			Author:      cm.author.name,
+			AuthorColor: cm.author.color.CSS(),
+			Date:        cm.authorTime.Format("2006-01-02"),
+			Summary:     cm.summary,
+			URL:         commitURL(cm),
+		})
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
+
+type jsonIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type jsonAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Color string `json:"color"`
+	// Identities are the names and emails unified into this author.
+	Identities []jsonIdentity `json:"identities"`
+}
+
+type jsonCommit struct {
+	SHA           string       `json:"sha"`
+	Author        jsonAuthor   `json:"author"`
+	AuthorTime    time.Time    `json:"author_time"`
+	Committer     jsonIdentity `json:"committer"`
+	CommitterTime time.Time    `json:"committer_time"`
+	Summary       string       `json:"summary"`
+	Color         string       `json:"color"`
+	Boundary      bool         `json:"boundary"`
+	Uncommitted   bool         `json:"uncommitted"`
+}
+
+type jsonLine struct {
+	Line         int    `json:"line"`
+	Text         string `json:"text"`
+	Commit       string `json:"commit"`
+	OriginalPath string `json:"original_path"`
+	OriginalLine int    `json:"original_line"`
+}
+
+type jsonBlame struct {
+	Path     string       `json:"path"`
+	Rev      string       `json:"rev"`
+	Reverse  bool         `json:"reverse"`
+	Since    string       `json:"since,omitempty"`
+	Boundary string       `json:"boundary,omitempty"`
+	Commits  []jsonCommit `json:"commits"`
+	Lines    []jsonLine   `json:"lines"`
+}
+
+// inZone returns t in the time zone tz as recorded by git, e.g. -0700, and
+// t unchanged if tz is invalid.
+func inZone(t time.Time, tz string) time.Time {
+	offset, err := strconv.Atoi(tz)
+	if err != nil || len(tz) != 5 {
+		return t
+	}
+	sign := 1
+	if offset < 0 {
+		sign, offset = -1, -offset
+	}
+	return t.In(time.FixedZone(tz, sign*(offset/100*3600+offset%100*60)))
+}
+
+// writeBlameJSON writes data as JSON, commits youngest first followed by the
+// uncommitted changes if any, lines refer to commits by sha. Line numbers are
+// 1-based.
+func writeBlameJSON(w io.Writer, data *blameData) error {
+	out := jsonBlame{
+		Path:     data.path,
+		Rev:      data.rev,
+		Reverse:  data.opts.reverse,
+		Since:    data.opts.since,
+		Boundary: data.opts.boundary,
+		Commits:  []jsonCommit{},
+		Lines:    []jsonLine{},
+	}
+
+	commits := append([]*commit{}, data.sortedCommits...)
+	if cm, ok := data.commits[uncommittedSHA]; ok {
+		commits = append(commits, cm)
+	}
+	for _, cm := range commits {
+		a := jsonAuthor{Name: cm.author.name, Email: cm.author.email, Color: cm.author.color.CSS(), Identities: []jsonIdentity{}}
+		for _, id := range cm.author.identities {
+			a.Identities = append(a.Identities, jsonIdentity{Name: id.name, Email: id.email})
+		}
+		out.Commits = append(out.Commits, jsonCommit{
+			SHA:           cm.sha,
+			Author:        a,
+			AuthorTime:    inZone(cm.authorTime, cm.authorTZ),
+			Committer:     jsonIdentity{Name: cm.committer.name, Email: cm.committer.email},
+			CommitterTime: inZone(cm.committerTime, cm.committerTZ),
+			Summary:       cm.summary,
+			Color:         cm.color.CSS(),
+			Boundary:      cm.boundary,
+			Uncommitted:   cm.sha == uncommittedSHA,
+		})
+	}
+
+	for i, line := range data.lines {
+		o := data.origins[i]
+		out.Lines = append(out.Lines, jsonLine{
+			Line:         data.fileLine(i) + 1,
+			Text:         line,
+			Commit:       data.lineCommits[i].sha,
+			OriginalPath: o.path,
+			OriginalLine: o.line + 1,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// exportForge returns the forge of the preferred remote of the repository at
+// dir, nil if there is none.
+func exportForge(dir string) forge {
+	remotes, err := listRemotes(dir)
+	if err != nil {
+		messages.add(levelWarn, "failed to list remotes err=%v", err)
+		return nil
+	}
+	for _, r := range remotes {
+		if r.forge != nil {
+			return r.forge
+		}
+	}
+	return nil
+}
+
+// linkRevision returns the commit that data's lines can be linked at, HEAD
+// for the working tree.
+func linkRevision(dir string, data *blameData) (string, error) {
+	rev := data.rev
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return resolveRev(dir, rev)
+}
+
+// exportHTML writes the current blame to an HTML file in the temporary
+// directory.
+func (c *container) exportHTML() {
+	cd, err := cmdDir(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get cmd dir err=%w", err))
+		return
+	}
+	linkRev, err := linkRevision(cd, c.data)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to resolve revision err=%w", err))
+		return
+	}
+
+	pattern := fmt.Sprintf("gb-%s-%s-*.html", filepath.Base(c.filePath), linkRev[:8])
+	fh, err := os.CreateTemp("", pattern)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to create export file err=%w", err))
+		return
+	}
+	fp := fh.Name()
+
+	err = writeHTML(fh, c.data, c.forge, linkRev, c.colorMode)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		c.fail(fmt.Errorf("failed to export %s err=%w", fp, err))
+		return
+	}
+	messages.add(levelInfo, "exported blame to %s", fp)
+	c.info(fmt.Sprintf("exported to %s", fp))
+}
+
+// runExport implements the export subcommand, which writes the blame of a
+// file as an HTML page or as JSON.
+func runExport(args []string, out, errOut io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	format := fs.String("format", "html", "output format: html or json")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	color := fs.String("color", colorByRank.String(), "coloring mode: rank, age, author or commit")
+	since := fs.String("since", "", "attribute lines only to commits more recent than this date")
+	revRange := fs.String("range", "", "attribute lines only to commits in the revision range A..B")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "usage: gb export [flags] file|rev:path")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one file, got %d", fs.NArg())
+	}
+	if *format != "html" && *format != "json" {
+		return fmt.Errorf("unknown format %#v", *format)
+	}
+	mode, ok := parseColorMode(*color)
+	if !ok {
+		return fmt.Errorf("unknown coloring mode %#v", *color)
+	}
+
+	t, err := newTab(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("can't open %#v err=%w", fs.Arg(0), err)
+	}
+	opts := blameOptions{since: *since}
+	if *revRange != "" {
+		var end string
+		opts.boundary, end, err = parseRange(*revRange)
+		if err != nil {
+			return err
+		}
+		if end != "" && t.startRev != "" && t.startRev != end {
+			return fmt.Errorf("range %#v conflicts with the revision of %#v, use %s.. instead", *revRange, fs.Arg(0), opts.boundary)
+		}
+		if t.startRev == "" {
+			t.startRev = end
+		}
+	}
+
+	data, err := blame(t.filePath, t.startRev, opts)
+	if err != nil {
+		return fmt.Errorf("failed to blame %s err=%w", t.filePath, err)
+	}
+	colorCommits(data, mode, time.Now())
+
+	write := func(w io.Writer) error { return writeBlameJSON(w, data) }
+	if *format == "html" {
+		cd, err := cmdDir(t.filePath)
+		if err != nil {
+			return err
+		}
+		linkRev, err := linkRevision(cd, data)
+		if err != nil {
+			return fmt.Errorf("failed to resolve revision err=%w", err)
+		}
+		write = func(w io.Writer) error { return writeHTML(w, data, exportForge(cd), linkRev, mode) }
+	}
+
+	if *output == "" {
+		return write(out)
+	}
+	fh, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = write(fh)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
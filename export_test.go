@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportFixture is the porcelain blame of main.go with a boundary commit, a
+// line moved from util.go and an uncommitted line.
+const exportFixture = `1111111111111111111111111111111111111111 1 1 2
+author Alice
+author-mail <alice@example.com>
+author-time 1700000000
+author-tz +0200
+committer Bob
+committer-mail <bob@example.com>
+committer-time 1700003600
+committer-tz -0700
+summary Add <main> & friends
+boundary
+filename main.go
+	package main
+1111111111111111111111111111111111111111 2 2
+	// main
+2222222222222222222222222222222222222222 5 3 1
+author Carol
+author-mail <carol@example.com>
+author-time 1700086400
+author-tz +0530
+committer Carol
+committer-mail <carol@example.com>
+committer-time 1700086400
+committer-tz +0530
+summary Move helper
+previous 1111111111111111111111111111111111111111 util.go
+filename util.go
+	func helper() string { return "<b>" }
+0000000000000000000000000000000000000000 4 4 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1700090000
+author-tz +0000
+committer Not Committed Yet
+committer-mail <not.committed.yet>
+committer-time 1700090000
+committer-tz +0000
+summary Version of main.go from main.go
+previous 2222222222222222222222222222222222222222 main.go
+filename main.go
+	// TODO & more
+`
+
+func parseExportFixture(t *testing.T, out string) *blameData {
+	t.Helper()
+	data, err := parseBlameOutput(out)
+	if err != nil {
+		t.Fatalf("failed to parse fixture err=%v", err)
+	}
+	data.path = "main.go"
+	colorCommits(data, colorByRank, time.Unix(1700100000, 0))
+	return data
+}
+
+func TestWriteHTML(t *testing.T) {
+	data := parseExportFixture(t, exportFixture)
+	repo := remoteRepo{scheme: "ssh", host: "github.com", path: "fgeller/gb"}
+	f := githubForge{baseURL: repo.webURL(), repo: repo}
+	const linkRev = "3333333333333333333333333333333333333333"
+
+	buf := &strings.Builder{}
+	if err := writeHTML(buf, data, f, linkRev, colorByRank); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	out := buf.String()
+
+	contains := []string{
+		"<title>main.go</title>",
+		"<span>working tree</span><span>coloring by rank</span>",
+		// code and summaries are escaped.
+		`<td class="code">func helper() string { return &#34;&lt;b&gt;&#34; }</td>`,
+		`<td class="code">// TODO &amp; more</td>`,
+		"<td>Add &lt;main&gt; &amp; friends</td>",
+		// the tooltip has the full sha, author, date with zone and summary.
+		"title=\"2222222222222222222222222222222222222222\nCarol &lt;carol@example.com&gt;\n",
+		"\nMove helper\"",
+		`title="not committed yet"`,
+		// commits and lines link to the forge, uncommitted lines don't.
+		`<a href="https://github.com/fgeller/gb/commit/2222222222222222222222222222222222222222" style="color: `,
+		`<a href="https://github.com/fgeller/gb/blob/` + linkRev + `/main.go#L3">3</a>`,
+		`<span style="color: ` + data.commits[uncommittedSHA].color.CSS() + `">00000000</span>`,
+		// boundary lines are marked and moved lines show their origin.
+		`<tr id="L1" class="boundary"`,
+		">^1111111</a>",
+		`<td class="origin">← util.go:5</td>`,
+	}
+	for _, s := range contains {
+		if !strings.Contains(out, s) {
+			t.Errorf("html doesn't contain %s", s)
+		}
+	}
+	if strings.Contains(out, "<b>") || strings.Contains(out, "<main>") {
+		t.Errorf("html contains unescaped code or summary")
+	}
+	if strings.Contains(out, "commit/0000000000000000000000000000000000000000") {
+		t.Errorf("html links the uncommitted changes")
+	}
+
+	buf.Reset()
+	if err := writeHTML(buf, data, nil, "", colorByAge); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	out = buf.String()
+	if strings.Contains(out, "href=\"http") {
+		t.Errorf("html without forge contains links")
+	}
+	if !strings.Contains(out, `<a href="#L3">3</a>`) || !strings.Contains(out, "coloring by age") {
+		t.Errorf("html without forge doesn't anchor lines to the page")
+	}
+}
+
+func TestWriteBlameJSON(t *testing.T) {
+	// the fixture as blamed for a function starting on line 10.
+	fn := strings.NewReplacer(" 1 1 2\n", " 1 10 2\n", " 2 2\n", " 2 11\n", " 5 3 1\n", " 5 12 1\n", " 4 4 1\n", " 4 13 1\n").Replace(exportFixture)
+	data := parseExportFixture(t, fn)
+	data.rev = "v1.1"
+	data.opts = blameOptions{boundary: "v1.0", function: "main"}
+
+	buf := &strings.Builder{}
+	if err := writeBlameJSON(buf, data); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the field names are the schema that scripts rely on.
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(buf.String()), &raw); err != nil {
+		t.Fatalf("invalid json err=%v: %s", err, buf)
+	}
+	keys := func(v any) []string {
+		res := []string{}
+		for k := range v.(map[string]any) {
+			res = append(res, k)
+		}
+		sort.Strings(res)
+		return res
+	}
+	commit := raw["commits"].([]any)[0]
+	schema := map[string][]string{
+		"blame":     keys(raw),
+		"commit":    keys(commit),
+		"author":    keys(commit.(map[string]any)["author"]),
+		"identity":  keys(commit.(map[string]any)["author"].(map[string]any)["identities"].([]any)[0]),
+		"committer": keys(commit.(map[string]any)["committer"]),
+		"line":      keys(raw["lines"].([]any)[0]),
+	}
+	wantSchema := map[string][]string{
+		"blame":     {"boundary", "commits", "lines", "path", "rev", "reverse"},
+		"commit":    {"author", "author_time", "boundary", "color", "committer", "committer_time", "sha", "summary", "uncommitted"},
+		"author":    {"color", "email", "identities", "name"},
+		"identity":  {"email", "name"},
+		"committer": {"email", "name"},
+		"line":      {"commit", "line", "original_line", "original_path", "text"},
+	}
+	if !reflect.DeepEqual(schema, wantSchema) {
+		t.Errorf("schema = %v, want %v", schema, wantSchema)
+	}
+
+	var got jsonBlame
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("invalid json err=%v: %s", err, buf)
+	}
+	if got.Path != "main.go" || got.Rev != "v1.1" || got.Reverse || got.Boundary != "v1.0" || got.Since != "" {
+		t.Errorf("blame = %s %s reverse %v boundary %s since %s, want main.go at v1.1 with boundary v1.0", got.Path, got.Rev, got.Reverse, got.Boundary, got.Since)
+	}
+
+	type commitSummary struct {
+		sha, committer, authorTime, committerTime string
+		boundary, uncommitted                     bool
+	}
+	commits := []commitSummary{}
+	for _, cm := range got.Commits {
+		commits = append(commits, commitSummary{
+			sha:           cm.SHA[:8],
+			committer:     cm.Committer.Name + " <" + cm.Committer.Email + ">",
+			authorTime:    cm.AuthorTime.Format(time.RFC3339),
+			committerTime: cm.CommitterTime.Format(time.RFC3339),
+			boundary:      cm.Boundary,
+			uncommitted:   cm.Uncommitted,
+		})
+	}
+	// youngest first, the uncommitted changes last, times in their recorded zones.
+	wantCommits := []commitSummary{
+		{sha: "22222222", committer: "Carol <carol@example.com>", authorTime: "2023-11-16T03:43:20+05:30", committerTime: "2023-11-16T03:43:20+05:30"},
+		{sha: "11111111", committer: "Bob <bob@example.com>", authorTime: "2023-11-15T00:13:20+02:00", committerTime: "2023-11-14T16:13:20-07:00", boundary: true},
+		{sha: "00000000", committer: "Not Committed Yet <not.committed.yet>", authorTime: "2023-11-15T23:13:20Z", committerTime: "2023-11-15T23:13:20Z", uncommitted: true},
+	}
+	if !reflect.DeepEqual(commits, wantCommits) {
+		t.Errorf("commits = %+v, want %+v", commits, wantCommits)
+	}
+	if got.Commits[1].Author.Name != "Alice" || got.Commits[1].Summary != "Add <main> & friends" {
+		t.Errorf("boundary commit = %+v, want Alice's", got.Commits[1])
+	}
+
+	wantLines := []jsonLine{
+		{Line: 10, Text: "package main", Commit: strings.Repeat("1", 40), OriginalPath: "main.go", OriginalLine: 1},
+		{Line: 11, Text: "// main", Commit: strings.Repeat("1", 40), OriginalPath: "main.go", OriginalLine: 2},
+		{Line: 12, Text: `func helper() string { return "<b>" }`, Commit: strings.Repeat("2", 40), OriginalPath: "util.go", OriginalLine: 5},
+		{Line: 13, Text: "// TODO & more", Commit: uncommittedSHA, OriginalPath: "main.go", OriginalLine: 4},
+	}
+	if !reflect.DeepEqual(got.Lines, wantLines) {
+		t.Errorf("lines = %+v, want %+v", got.Lines, wantLines)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// forge builds web URLs for a repository hosted on GitHub, GitLab, Bitbucket,
+// Gitea/Forgejo or Azure DevOps.
+//
+// Well-known hosts are recognized automatically, self-hosted instances can be
+// configured per hostname via git config, e.g.:
+//
+//	git config gb.git.example.com.forge gitlab
+type forge interface {
+	name() string
+	// pullRequestRef extracts a single pull request reference from a commit
+	// summary, it returns "" if there is none or more than one.
+	pullRequestRef(summary string) string
+	pullRequestURL(ref string) string
+	commitURL(sha string) string
+	// fileURL links to path, relative to the repository root, at rev,
+	// highlighting lines start to end (1-based) if they are positive.
+	fileURL(rev, path string, start, end int) string
+	// compareURL links to the changes between revisions from and to.
+	compareURL(from, to string) string
+}
+
+const (
+	forgeGitHub    = "github"
+	forgeGitLab    = "gitlab"
+	forgeBitbucket = "bitbucket"
+	forgeGitea     = "gitea"
+	forgeAzure     = "azure"
+)
+
+var knownForgeHosts = map[string]string{
+	"github.com":              forgeGitHub,
+	"gitlab.com":              forgeGitLab,
+	"bitbucket.org":           forgeBitbucket,
+	"codeberg.org":            forgeGitea,
+	"gitea.com":               forgeGitea,
+	"dev.azure.com":           forgeAzure,
+	"ssh.dev.azure.com":       forgeAzure,
+	"vs-ssh.visualstudio.com": forgeAzure,
+}
+
+// forgeKind determines the forge hosting host. configured maps hostnames to
+// forge kinds as set by the user and takes precedence.
+func forgeKind(host string, configured map[string]string) string {
+	if kind, ok := configured[host]; ok {
+		return kind
+	}
+	if kind, ok := knownForgeHosts[host]; ok {
+		return kind
+	}
+	if strings.HasSuffix(host, ".visualstudio.com") {
+		return forgeAzure
+	}
+	// self-hosted Bitbucket Server uses different URLs than bitbucket.org, so
+	// other hosts have to be configured explicitly.
+	for _, kind := range []string{forgeGitLab, forgeGitea} {
+		if strings.Contains(host, kind) {
+			return kind
+		}
+	}
+	if strings.Contains(host, "forgejo") {
+		return forgeGitea
+	}
+	return ""
+}
+
+// newForge returns the forge for repo, nil if the host is not recognized.
+func newForge(repo remoteRepo, configured map[string]string) forge {
+	base := repo.webURL()
+	switch forgeKind(repo.host, configured) {
+	case forgeGitHub:
+		return githubForge{baseURL: base, repo: repo}
+	case forgeGitLab:
+		return gitlabForge{baseURL: base, repo: repo}
+	case forgeBitbucket:
+		return bitbucketForge{baseURL: base, repo: repo}
+	case forgeGitea:
+		return giteaForge{baseURL: base, repo: repo}
+	case forgeAzure:
+		return newAzureForge(repo)
+	}
+	return nil
+}
+
+// configuredForges reads the hostname to forge kind mapping from the
+// gb.<host>.forge git config keys.
+func configuredForges(dir string) map[string]string {
+	res := map[string]string{}
+	buf, err := runGit(dir, "config", "--get-regexp", `^gb\..+\.forge$`)
+	if err != nil {
+		return res
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+		key, kind, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		host := strings.TrimSuffix(strings.TrimPrefix(key, "gb."), ".forge")
+		res[strings.ToLower(host)] = strings.ToLower(strings.TrimSpace(kind))
+	}
+	return res
+}
+
+func joinURL(base string, elem ...string) string {
+	res, err := url.JoinPath(base, elem...)
+	if err != nil {
+		return base
+	}
+	return res
+}
+
+var (
+	rxHashRef  = regexp.MustCompile(`#([0-9]+)`)
+	rxBangRef  = regexp.MustCompile(`!([0-9]+)`)
+	rxAzureRef = regexp.MustCompile(`^Merged PR ([0-9]+)`)
+)
+
+func extractReference(rx *regexp.Regexp, summary string) string {
+	matches := rx.FindAllStringSubmatch(summary, -1)
+	if len(matches) == 1 && len(matches[0]) == 2 {
+		return matches[0][1]
+	}
+	return ""
+}
+
+func extractPullRequestReference(summary string) string {
+	return extractReference(rxHashRef, summary)
+}
+
+// lineFragment formats start and end as a fragment with the given prefix and
+// separator, e.g. #L3-L5 for GitHub.
+func lineFragment(start, end int, prefix, sep string) string {
+	if start <= 0 {
+		return ""
+	}
+	if end <= start {
+		return fmt.Sprintf("#%s%d", prefix, start)
+	}
+	return fmt.Sprintf("#%s%d%s%d", prefix, start, sep, end)
+}
+
+type githubForge struct {
+	baseURL string
+	repo    remoteRepo
+}
+
+func (f githubForge) name() string { return forgeGitHub }
+
+func (f githubForge) pullRequestRef(summary string) string {
+	return extractPullRequestReference(summary)
+}
+
+func (f githubForge) pullRequestURL(ref string) string {
+	return joinURL(f.baseURL, "pull", ref)
+}
+
+func (f githubForge) commitURL(sha string) string {
+	return joinURL(f.baseURL, "commit", sha)
+}
+
+func (f githubForge) fileURL(rev, path string, start, end int) string {
+	return joinURL(f.baseURL, "blob", rev, path) + lineFragment(start, end, "L", "-L")
+}
+
+func (f githubForge) compareURL(from, to string) string {
+	return joinURL(f.baseURL, "compare", from+"..."+to)
+}
+
+type gitlabForge struct {
+	baseURL string
+	repo    remoteRepo
+}
+
+func (f gitlabForge) name() string { return forgeGitLab }
+
+func (f gitlabForge) pullRequestRef(summary string) string {
+	return extractReference(rxBangRef, summary)
+}
+
+func (f gitlabForge) pullRequestURL(ref string) string {
+	return joinURL(f.baseURL, "-", "merge_requests", ref)
+}
+
+func (f gitlabForge) commitURL(sha string) string {
+	return joinURL(f.baseURL, "-", "commit", sha)
+}
+
+func (f gitlabForge) fileURL(rev, path string, start, end int) string {
+	return joinURL(f.baseURL, "-", "blob", rev, path) + lineFragment(start, end, "L", "-")
+}
+
+func (f gitlabForge) compareURL(from, to string) string {
+	return joinURL(f.baseURL, "-", "compare", from+"..."+to)
+}
+
+type bitbucketForge struct {
+	baseURL string
+	repo    remoteRepo
+}
+
+func (f bitbucketForge) name() string { return forgeBitbucket }
+
+func (f bitbucketForge) pullRequestRef(summary string) string {
+	return extractPullRequestReference(summary)
+}
+
+func (f bitbucketForge) pullRequestURL(ref string) string {
+	return joinURL(f.baseURL, "pull-requests", ref)
+}
+
+func (f bitbucketForge) commitURL(sha string) string {
+	return joinURL(f.baseURL, "commits", sha)
+}
+
+func (f bitbucketForge) fileURL(rev, path string, start, end int) string {
+	return joinURL(f.baseURL, "src", rev, path) + lineFragment(start, end, "lines-", ":")
+}
+
+// compareURL uses Bitbucket's branch comparison which lists the target first,
+// separated by an escaped carriage return.
+func (f bitbucketForge) compareURL(from, to string) string {
+	return joinURL(f.baseURL, "branches", "compare") + "/" + to + "%0D" + from + "#diff"
+}
+
+type giteaForge struct {
+	baseURL string
+	repo    remoteRepo
+}
+
+func (f giteaForge) name() string { return forgeGitea }
+
+func (f giteaForge) pullRequestRef(summary string) string {
+	return extractPullRequestReference(summary)
+}
+
+func (f giteaForge) pullRequestURL(ref string) string {
+	return joinURL(f.baseURL, "pulls", ref)
+}
+
+func (f giteaForge) commitURL(sha string) string {
+	return joinURL(f.baseURL, "commit", sha)
+}
+
+func (f giteaForge) fileURL(rev, path string, start, end int) string {
+	return joinURL(f.baseURL, "src", "commit", rev, path) + lineFragment(start, end, "L", "-L")
+}
+
+func (f giteaForge) compareURL(from, to string) string {
+	return joinURL(f.baseURL, "compare", from+"..."+to)
+}
+
+type azureForge struct{ baseURL string }
+
+// newAzureForge maps the different Azure DevOps remote URL layouts to the web
+// URL https://dev.azure.com/org/project/_git/repo:
+//
+//	git@ssh.dev.azure.com:v3/org/project/repo
+//	https://org@dev.azure.com/org/project/_git/repo
+//	https://org.visualstudio.com/project/_git/repo
+func newAzureForge(repo remoteRepo) forge {
+	parts := strings.Split(repo.path, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "v3":
+		return azureForge{baseURL: "https://dev.azure.com/" + strings.Join([]string{parts[1], parts[2], "_git", parts[3]}, "/")}
+	case strings.HasSuffix(repo.host, ".visualstudio.com") && repo.host != "vs-ssh.visualstudio.com":
+		return azureForge{baseURL: "https://" + repo.host + "/" + repo.path}
+	}
+	return azureForge{baseURL: "https://dev.azure.com/" + repo.path}
+}
+
+func (f azureForge) name() string { return forgeAzure }
+
+func (f azureForge) pullRequestRef(summary string) string {
+	if ref := extractReference(rxAzureRef, summary); ref != "" {
+		return ref
+	}
+	return extractPullRequestReference(summary)
+}
+
+func (f azureForge) pullRequestURL(ref string) string {
+	return joinURL(f.baseURL, "pullrequest", ref)
+}
+
+func (f azureForge) commitURL(sha string) string {
+	return joinURL(f.baseURL, "commit", sha)
+}
+
+func (f azureForge) fileURL(rev, path string, start, end int) string {
+	q := url.Values{}
+	q.Set("path", "/"+path)
+	q.Set("version", "GC"+rev)
+	if start > 0 {
+		q.Set("line", fmt.Sprint(start))
+		q.Set("lineEnd", fmt.Sprint(max(start, end)+1))
+		q.Set("lineStartColumn", "1")
+		q.Set("lineEndColumn", "1")
+	}
+	return f.baseURL + "?" + q.Encode()
+}
+
+func (f azureForge) compareURL(from, to string) string {
+	q := url.Values{}
+	q.Set("baseVersion", "GC"+from)
+	q.Set("targetVersion", "GC"+to)
+	return joinURL(f.baseURL, "branchCompare") + "?" + q.Encode()
+}
@@ -0,0 +1,183 @@
+package main
+
+import "testing"
+
+func TestForgeKind(t *testing.T) {
+	configured := map[string]string{"git.example.com": forgeGitLab, "bitbucket.example.com": forgeBitbucket}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "github.com", want: forgeGitHub},
+		{host: "gitlab.com", want: forgeGitLab},
+		{host: "bitbucket.org", want: forgeBitbucket},
+		{host: "codeberg.org", want: forgeGitea},
+		{host: "dev.azure.com", want: forgeAzure},
+		{host: "ssh.dev.azure.com", want: forgeAzure},
+		{host: "vs-ssh.visualstudio.com", want: forgeAzure},
+		{host: "org.visualstudio.com", want: forgeAzure},
+		{host: "gitlab.example.com", want: forgeGitLab},
+		{host: "gitea.example.com", want: forgeGitea},
+		{host: "forgejo.example.com", want: forgeGitea},
+		{host: "git.example.com", want: forgeGitLab},
+		{host: "bitbucket.example.com", want: forgeBitbucket},
+		{host: "bitbucket.internal", want: ""},
+		{host: "example.com", want: ""},
+	}
+	for _, tt := range tests {
+		got := forgeKind(tt.host, configured)
+		if got != tt.want {
+			t.Errorf("forgeKind(%#v) = %#v, want %#v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestForgeURLs(t *testing.T) {
+	const sha = "82b270dc3ea611882e909887ac61053fb06ce962"
+	tests := []struct {
+		name        string
+		repo        remoteRepo
+		commit      string
+		file        string
+		fileLine    string
+		fileRange   string
+		compare     string
+		pullRequest string
+	}{
+		{
+			name:        "github",
+			repo:        remoteRepo{scheme: "ssh", host: "github.com", path: "fgeller/gb"},
+			commit:      "https://github.com/fgeller/gb/commit/" + sha,
+			file:        "https://github.com/fgeller/gb/blob/" + sha + "/cmd/main.go",
+			fileLine:    "https://github.com/fgeller/gb/blob/" + sha + "/cmd/main.go#L3",
+			fileRange:   "https://github.com/fgeller/gb/blob/" + sha + "/cmd/main.go#L3-L5",
+			compare:     "https://github.com/fgeller/gb/compare/b2427790...82b270dc",
+			pullRequest: "https://github.com/fgeller/gb/pull/12",
+		},
+		{
+			name:        "gitlab subgroup",
+			repo:        remoteRepo{scheme: "https", host: "gitlab.com", path: "group/sub/gb"},
+			commit:      "https://gitlab.com/group/sub/gb/-/commit/" + sha,
+			file:        "https://gitlab.com/group/sub/gb/-/blob/" + sha + "/cmd/main.go",
+			fileLine:    "https://gitlab.com/group/sub/gb/-/blob/" + sha + "/cmd/main.go#L3",
+			fileRange:   "https://gitlab.com/group/sub/gb/-/blob/" + sha + "/cmd/main.go#L3-5",
+			compare:     "https://gitlab.com/group/sub/gb/-/compare/b2427790...82b270dc",
+			pullRequest: "https://gitlab.com/group/sub/gb/-/merge_requests/12",
+		},
+		{
+			name:        "self-hosted gitlab with port",
+			repo:        remoteRepo{scheme: "https", host: "gitlab.example.com", port: "8443", path: "group/gb"},
+			commit:      "https://gitlab.example.com:8443/group/gb/-/commit/" + sha,
+			file:        "https://gitlab.example.com:8443/group/gb/-/blob/" + sha + "/cmd/main.go",
+			fileLine:    "https://gitlab.example.com:8443/group/gb/-/blob/" + sha + "/cmd/main.go#L3",
+			fileRange:   "https://gitlab.example.com:8443/group/gb/-/blob/" + sha + "/cmd/main.go#L3-5",
+			compare:     "https://gitlab.example.com:8443/group/gb/-/compare/b2427790...82b270dc",
+			pullRequest: "https://gitlab.example.com:8443/group/gb/-/merge_requests/12",
+		},
+		{
+			name:        "bitbucket",
+			repo:        remoteRepo{scheme: "ssh", host: "bitbucket.org", path: "fgeller/gb"},
+			commit:      "https://bitbucket.org/fgeller/gb/commits/" + sha,
+			file:        "https://bitbucket.org/fgeller/gb/src/" + sha + "/cmd/main.go",
+			fileLine:    "https://bitbucket.org/fgeller/gb/src/" + sha + "/cmd/main.go#lines-3",
+			fileRange:   "https://bitbucket.org/fgeller/gb/src/" + sha + "/cmd/main.go#lines-3:5",
+			compare:     "https://bitbucket.org/fgeller/gb/branches/compare/82b270dc%0Db2427790#diff",
+			pullRequest: "https://bitbucket.org/fgeller/gb/pull-requests/12",
+		},
+		{
+			name:        "gitea",
+			repo:        remoteRepo{scheme: "ssh", host: "codeberg.org", path: "fgeller/gb"},
+			commit:      "https://codeberg.org/fgeller/gb/commit/" + sha,
+			file:        "https://codeberg.org/fgeller/gb/src/commit/" + sha + "/cmd/main.go",
+			fileLine:    "https://codeberg.org/fgeller/gb/src/commit/" + sha + "/cmd/main.go#L3",
+			fileRange:   "https://codeberg.org/fgeller/gb/src/commit/" + sha + "/cmd/main.go#L3-L5",
+			compare:     "https://codeberg.org/fgeller/gb/compare/b2427790...82b270dc",
+			pullRequest: "https://codeberg.org/fgeller/gb/pulls/12",
+		},
+		{
+			name:        "azure ssh v3",
+			repo:        remoteRepo{scheme: "ssh", host: "ssh.dev.azure.com", path: "v3/org/project/gb"},
+			commit:      "https://dev.azure.com/org/project/_git/gb/commit/" + sha,
+			file:        "https://dev.azure.com/org/project/_git/gb?path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileLine:    "https://dev.azure.com/org/project/_git/gb?line=3&lineEnd=4&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileRange:   "https://dev.azure.com/org/project/_git/gb?line=3&lineEnd=6&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			compare:     "https://dev.azure.com/org/project/_git/gb/branchCompare?baseVersion=GCb2427790&targetVersion=GC82b270dc",
+			pullRequest: "https://dev.azure.com/org/project/_git/gb/pullrequest/12",
+		},
+		{
+			name:        "azure https",
+			repo:        remoteRepo{scheme: "https", host: "dev.azure.com", path: "org/project/_git/gb"},
+			commit:      "https://dev.azure.com/org/project/_git/gb/commit/" + sha,
+			file:        "https://dev.azure.com/org/project/_git/gb?path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileLine:    "https://dev.azure.com/org/project/_git/gb?line=3&lineEnd=4&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileRange:   "https://dev.azure.com/org/project/_git/gb?line=3&lineEnd=6&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			compare:     "https://dev.azure.com/org/project/_git/gb/branchCompare?baseVersion=GCb2427790&targetVersion=GC82b270dc",
+			pullRequest: "https://dev.azure.com/org/project/_git/gb/pullrequest/12",
+		},
+		{
+			name:        "azure visualstudio.com",
+			repo:        remoteRepo{scheme: "https", host: "org.visualstudio.com", path: "project/_git/gb"},
+			commit:      "https://org.visualstudio.com/project/_git/gb/commit/" + sha,
+			file:        "https://org.visualstudio.com/project/_git/gb?path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileLine:    "https://org.visualstudio.com/project/_git/gb?line=3&lineEnd=4&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileRange:   "https://org.visualstudio.com/project/_git/gb?line=3&lineEnd=6&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			compare:     "https://org.visualstudio.com/project/_git/gb/branchCompare?baseVersion=GCb2427790&targetVersion=GC82b270dc",
+			pullRequest: "https://org.visualstudio.com/project/_git/gb/pullrequest/12",
+		},
+		{
+			name:        "azure visualstudio.com ssh",
+			repo:        remoteRepo{scheme: "ssh", host: "vs-ssh.visualstudio.com", path: "v3/org/project/gb"},
+			commit:      "https://dev.azure.com/org/project/_git/gb/commit/" + sha,
+			file:        "https://dev.azure.com/org/project/_git/gb?path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileLine:    "https://dev.azure.com/org/project/_git/gb?line=3&lineEnd=4&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			fileRange:   "https://dev.azure.com/org/project/_git/gb?line=3&lineEnd=6&lineEndColumn=1&lineStartColumn=1&path=%2Fcmd%2Fmain.go&version=GC" + sha,
+			compare:     "https://dev.azure.com/org/project/_git/gb/branchCompare?baseVersion=GCb2427790&targetVersion=GC82b270dc",
+			pullRequest: "https://dev.azure.com/org/project/_git/gb/pullrequest/12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newForge(tt.repo, nil)
+			if f == nil {
+				t.Fatalf("no forge for %+v", tt.repo)
+			}
+			check := func(what, got, want string) {
+				t.Helper()
+				if got != want {
+					t.Errorf("%s\n got %s\nwant %s", what, got, want)
+				}
+			}
+			check("commitURL", f.commitURL(sha), tt.commit)
+			check("fileURL", f.fileURL(sha, "cmd/main.go", 0, 0), tt.file)
+			check("fileURL of line", f.fileURL(sha, "cmd/main.go", 3, 3), tt.fileLine)
+			check("fileURL of lines", f.fileURL(sha, "cmd/main.go", 3, 5), tt.fileRange)
+			check("compareURL", f.compareURL("b2427790", "82b270dc"), tt.compare)
+			check("pullRequestURL", f.pullRequestURL("12"), tt.pullRequest)
+		})
+	}
+}
+
+func TestPullRequestRef(t *testing.T) {
+	github := githubForge{baseURL: "https://github.com/fgeller/gb"}
+	gitlab := gitlabForge{baseURL: "https://gitlab.com/fgeller/gb"}
+	azure := azureForge{baseURL: "https://dev.azure.com/org/project/_git/gb"}
+	tests := []struct {
+		f       forge
+		summary string
+		want    string
+	}{
+		{f: github, summary: "Add feature (#12)", want: "12"},
+		{f: github, summary: "Fix #3 and #4", want: ""},
+		{f: github, summary: "Add feature", want: ""},
+		{f: gitlab, summary: "Add feature (!34)", want: "34"},
+		{f: gitlab, summary: "Add feature (#34)", want: ""},
+		{f: azure, summary: "Merged PR 56: Add feature", want: "56"},
+		{f: azure, summary: "Add feature (#78)", want: "78"},
+	}
+	for _, tt := range tests {
+		got := tt.f.pullRequestRef(tt.summary)
+		if got != tt.want {
+			t.Errorf("%s pullRequestRef(%#v) = %#v, want %#v", tt.f.name(), tt.summary, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// function is a function or method of a blamed file, start and end are the
+// indexes of its first and last line in the blame's lines.
+type function struct {
+	// name is qualified by the receiver for Go methods, e.g. container.showTab.
+	name  string
+	start int
+	end   int
+}
+
+// findFunctions returns the functions of lines by their start, parsing Go
+// and using heuristics for other common languages by the extension of path.
+// lines may be a function's lines only.
+func findFunctions(path string, lines []string) []function {
+	ext := strings.ToLower(filepath.Ext(path))
+	var res []function
+	switch {
+	case ext == ".go":
+		res = goFunctions(lines)
+	case ext == ".py":
+		res = pythonFunctions(lines)
+	case braceLanguages[ext] != nil:
+		res = braceFunctions(lines, braceLanguages[ext])
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].start < res[j].start })
+	return res
+}
+
+var rxGoPackage = regexp.MustCompile(`(?m)^package\s+\w+`)
+
+func goFunctions(lines []string) []function {
+	src := strings.Join(lines, "\n")
+	offset := 0
+	if !rxGoPackage.MatchString(src) {
+		src = "package p\n" + src
+		offset = 1
+	}
+
+	fset := token.NewFileSet()
+	// the parser returns what it could parse despite errors, e.g. for
+	// uncommitted changes that don't compile.
+	f, _ := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if f == nil {
+		return nil
+	}
+
+	res := []function{}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		name := fd.Name.Name
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			name = goReceiverName(fd.Recv.List[0].Type) + "." + name
+		}
+		res = append(res, function{
+			name:  name,
+			start: fset.Position(fd.Pos()).Line - 1 - offset,
+			end:   fset.Position(fd.End()).Line - 1 - offset,
+		})
+	}
+	return res
+}
+
+func goReceiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return goReceiverName(t.X)
+	case *ast.IndexExpr:
+		return goReceiverName(t.X)
+	case *ast.IndexListExpr:
+		return goReceiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+var rxPythonDef = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)\s*\(`)
+
+// pythonFunctions finds functions by def lines and their decorators, a
+// function ends before the next line that isn't indented further.
+func pythonFunctions(lines []string) []function {
+	res := []function{}
+	for i, line := range lines {
+		m := rxPythonDef.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent := len(m[1])
+		end := i
+		for j := i + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "" {
+				continue
+			}
+			lineIndent := len(lines[j]) - len(strings.TrimLeft(lines[j], " \t"))
+			// closing parens of signatures spanning several lines.
+			if lineIndent <= indent && !strings.HasPrefix(trimmed, ")") {
+				break
+			}
+			end = j
+		}
+		start := i
+		for start > 0 && strings.HasPrefix(lines[start-1], m[1]+"@") {
+			start -= 1
+		}
+		res = append(res, function{name: m[2], start: start, end: end})
+	}
+	return res
+}
+
+// braceLanguages match lines that start a function in languages with braced
+// bodies, capturing the function's name.
+var braceLanguages = map[string]*regexp.Regexp{}
+
+func init() {
+	// the return type may be on the line before, as in GNU style, if the
+	// name starts the line. Bodies may follow on the same line.
+	cLike := regexp.MustCompile(`^(?:\s*(?:[\w:<>,\[\]*&~]+\s+)+[*&]*([\w:~]+)|([A-Za-z_][\w:~]*))\s*\([^;{]*(?:\{.*)?$`)
+	js := regexp.MustCompile(`(?:^|\s)function\s*\*?\s*([\w$]+)\s*\(|^\s*(?:(?:export|const|let|var|static|async|public|private|protected|readonly)\s+)*([\w$]+)\s*[=:]\s*(?:async\s+)?(?:function\b[^(]*)?\([^)]*\)\s*(?::\s*[^=]+)?(?:=>|\{)|^\s*(?:(?:static|async|public|private|protected|get|set)\s+)*([\w$]+)\s*\([^)]*\)\s*(?::\s*[^{]+)?\{\s*$`)
+	for _, ext := range []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".java", ".cs"} {
+		braceLanguages[ext] = cLike
+	}
+	for _, ext := range []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"} {
+		braceLanguages[ext] = js
+	}
+	braceLanguages[".rs"] = regexp.MustCompile(`\bfn\s+(\w+)`)
+	braceLanguages[".kt"] = regexp.MustCompile(`\bfun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?(\w+)\s*\(`)
+	braceLanguages[".swift"] = regexp.MustCompile(`\bfunc\s+(\w+)`)
+	braceLanguages[".php"] = regexp.MustCompile(`\bfunction\s+&?(\w+)\s*\(`)
+	braceLanguages[".scala"] = regexp.MustCompile(`\bdef\s+(\w+)`)
+}
+
+// notFunctions are keywords that look like function names to the heuristics.
+var notFunctions = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"else": true, "new": true, "sizeof": true, "do": true, "function": true,
+}
+
+// rxDeclarationPrefix matches lines like "static int" or "@Override" that
+// belong to the declaration on the next line.
+var rxDeclarationPrefix = regexp.MustCompile(`^\s*@?[A-Za-z_][^;{}()/#=]*$`)
+
+var rxLiteral = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|//.*$`)
+
+// braceFunctions finds functions by lines matching rx, a function ends with
+// the brace closing the first one opened. Lines ending the statement before
+// a brace are declarations and skipped. A function starts with the lines
+// before its name that only hold modifiers, types or annotations.
+func braceFunctions(lines []string, rx *regexp.Regexp) []function {
+	res := []function{}
+	for i, line := range lines {
+		m := rx.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name := ""
+		for _, g := range m[1:] {
+			if g != "" {
+				name = g
+				break
+			}
+		}
+		if name == "" || notFunctions[name] {
+			continue
+		}
+
+		depth, opened, end := 0, false, -1
+	scan:
+		for j := i; j < len(lines) && (opened || j < i+5); j++ {
+			for _, r := range rxLiteral.ReplaceAllString(lines[j], "") {
+				switch {
+				case r == ';' && !opened:
+					break scan
+				case r == '{':
+					depth, opened = depth+1, true
+				case r == '}' && opened:
+					depth -= 1
+					if depth == 0 {
+						end = j
+						break scan
+					}
+				}
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		start := i
+		for start > 0 && rxDeclarationPrefix.MatchString(lines[start-1]) {
+			start -= 1
+		}
+		res = append(res, function{name: name, start: start, end: end})
+	}
+	return res
+}
+
+// functionRange returns the first and last line (0-based) of the function
+// called name in filePath at rev, "" for the working tree, the one starting
+// closest to line near if there are several. The lines are found by parsing
+// the file rather than by git's funcname matching, which only knows
+// definitions that start in the first column.
+func functionRange(filePath, rev, name string, near int) (int, int, error) {
+	path, err := repoPath(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var buf []byte
+	if rev == "" {
+		buf, err = os.ReadFile(filePath)
+	} else {
+		var cd string
+		cd, err = cmdDir(filePath)
+		if err == nil {
+			buf, err = runGit(cd, "cat-file", "blob", rev+":"+path)
+		}
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s err=%w", path, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	var res function
+	found := false
+	for _, fn := range findFunctions(path, lines) {
+		if fn.name != name {
+			continue
+		}
+		if !found || abs(fn.start-near) < abs(res.start-near) {
+			res, found = fn, true
+		}
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("no function %s in %s", name, path)
+	}
+	return res.start, res.end, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// functions returns the functions of the current blame.
+func (c *container) functions() []function {
+	return findFunctions(c.data.path, c.data.lines)
+}
+
+// functionAt returns the innermost function with line.
+func functionAt(fns []function, line int) (function, bool) {
+	var res function
+	found := false
+	for _, fn := range fns {
+		if line < fn.start || line > fn.end {
+			continue
+		}
+		if !found || fn.end-fn.start < res.end-res.start {
+			res, found = fn, true
+		}
+	}
+	return res, found
+}
+
+// gotoFunction moves to the start of the next function in direction delta.
+func (c *container) gotoFunction(delta int) {
+	fns := c.functions()
+	if delta < 0 {
+		for i := len(fns) - 1; i >= 0; i-- {
+			if fns[i].start < c.currentLine {
+				c.gotoLine(fns[i].start)
+				return
+			}
+		}
+		c.warn("no previous function")
+		return
+	}
+	for _, fn := range fns {
+		if fn.start > c.currentLine {
+			c.gotoLine(fn.start)
+			return
+		}
+	}
+	c.warn("no next function")
+}
+
+// toggleFunctionBlame restricts the blame to the function under the cursor,
+// or blames the whole file again.
+func (c *container) toggleFunctionBlame() {
+	opts := c.blameOptions()
+	if opts.function != "" {
+		opts.function, opts.funcStart = "", 0
+		c.navigateTo(position{filePath: c.filePath, rev: c.data.rev, line: c.data.fileLine(c.currentLine), opts: opts})
+		return
+	}
+
+	fn, ok := functionAt(c.functions(), c.currentLine)
+	if !ok {
+		c.warn("not in a function")
+		return
+	}
+	opts.function, opts.funcStart = fn.name, fn.start
+	c.navigateTo(position{filePath: c.filePath, rev: c.data.rev, line: c.currentLine - fn.start, opts: opts})
+}
+
+// functionSummary is when the lines of a function were last changed and by
+// whom.
+type functionSummary struct {
+	function
+	lastChange *commit
+	authors    []authorStats
+}
+
+func summarizeFunctions(data *blameData, fns []function) []functionSummary {
+	res := []functionSummary{}
+	for _, fn := range fns {
+		s := functionSummary{function: fn}
+		lines := data.slice(fn.start, min(fn.end, len(data.lines)-1))
+		for _, cm := range lines.lineCommits {
+			if s.lastChange == nil || cm.sha == uncommittedSHA || (s.lastChange.sha != uncommittedSHA && cm.authorTime.After(s.lastChange.authorTime)) {
+				s.lastChange = cm
+			}
+		}
+		s.authors = authorStatistics(lines)
+		res = append(res, s)
+	}
+	return res
+}
+
+// showFunctions lists the functions of the blame with their last change and
+// main authors, selecting one moves to it.
+func (c *container) showFunctions() {
+	fns := c.functions()
+	if len(fns) == 0 {
+		c.warn(fmt.Sprintf("no functions found in %s", filepath.Base(c.filePath)))
+		return
+	}
+
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetSelectedStyle(tcell.StyleDefault.
+			Foreground(tcell.ColorBlack.TrueColor()).
+			Background(tcell.GetColor("#e8ecf0").TrueColor()))
+	statsHeader(table, 0, " function", "lines", "changed", "", "authors")
+
+	black, green := tcell.ColorBlack, tcell.GetColor("#2E7D32")
+	current, _ := functionAt(fns, c.currentLine)
+	selected := 1
+	summaries := summarizeFunctions(c.data, fns)
+	for i, s := range summaries {
+		row := i + 1
+		if s.function == current {
+			selected = row
+		}
+
+		authors := []string{}
+		total := s.end - s.start + 1
+		for _, st := range s.authors[:min(2, len(s.authors))] {
+			authors = append(authors, fmt.Sprintf("[%s]%s[#000000] %s", st.author.color, tview.Escape(st.author.name), percent(st.lines, total)))
+		}
+
+		cm := s.lastChange
+		changed := cm.authorTime.Format("2006-01-02")
+		if cm.sha == uncommittedSHA {
+			changed = "uncommitted"
+		}
+		table.SetCell(row, 0, statsCell(" "+tview.Escape(s.name), black, tview.AlignLeft).SetMaxWidth(40))
+		table.SetCell(row, 1, statsCell(fmt.Sprint(total), black, tview.AlignRight))
+		table.SetCell(row, 2, statsCell(changed, green, tview.AlignRight))
+		table.SetCell(row, 3, statsCell(cm.sha[:8], cm.color, tview.AlignLeft))
+		table.SetCell(row, 4, statsCell(strings.Join(authors, ", "), black, tview.AlignLeft))
+	}
+
+	table.
+		SetBorder(true).
+		SetTitle(" functions ").
+		SetTitleColor(tcell.ColorBlack.TrueColor()).
+		SetBorderColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	table.SetFixed(1, 0)
+	table.SetSelectedFunc(func(row, _ int) {
+		c.closeModal("functions")
+		c.gotoLine(summaries[row-1].start)
+	})
+	table.SetDoneFunc(func(tcell.Key) { c.closeModal("functions") })
+	table.Select(selected, 0)
+
+	_, _, width, height := c.pages.GetRect()
+	c.showModal("functions", table, max(60, width-20), min(len(fns)+3, max(10, height-6)))
+}
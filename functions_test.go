@@ -0,0 +1,232 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		src  string
+		want []function
+	}{
+		{
+			name: "go methods with receivers",
+			path: "main.go",
+			src: `package main
+
+func main() {}
+
+// String formats t.
+func (t *tab) String() string {
+	return t.filePath
+}
+
+func (l list[T]) Len() int { return len(l) }
+
+func (m pair[K, V]) Keys() []K {
+	return nil
+}
+`,
+			want: []function{
+				{name: "main", start: 2, end: 2},
+				{name: "tab.String", start: 5, end: 7},
+				{name: "list.Len", start: 9, end: 9},
+				{name: "pair.Keys", start: 11, end: 13},
+			},
+		},
+		{
+			name: "go function lines only",
+			path: "main.go",
+			src: `func (c *container) render() {
+	if c.data == nil {
+		return
+	}
+}`,
+			want: []function{{name: "container.render", start: 0, end: 4}},
+		},
+		{
+			name: "python nested defs and decorators",
+			path: "a.py",
+			src: `import functools
+
+@functools.cache
+def outer(a,
+          b):
+    def inner():
+        return a
+
+    return inner
+
+
+class A:
+    @property
+    async def name(self):
+        return "a"
+`,
+			want: []function{
+				{name: "outer", start: 2, end: 8},
+				{name: "inner", start: 5, end: 6},
+				{name: "name", start: 12, end: 14},
+			},
+		},
+		{
+			name: "c declarations split across lines",
+			path: "a.c",
+			src: `static int add(int a, int b);
+
+static int
+add(int a,
+    int b)
+{
+	if (a) {
+		return a + b;
+	}
+	return b;
+}
+
+int main(void) { return add(1, 2); }
+`,
+			want: []function{
+				{name: "add", start: 2, end: 10},
+				{name: "main", start: 12, end: 12},
+			},
+		},
+		{
+			name: "java methods",
+			path: "A.java",
+			src: `public class A {
+    @Override
+    public static List<String> names(Map<String, Integer> m) throws IOException {
+        String s = "}";
+        return null; // }
+    }
+}
+`,
+			want: []function{{name: "names", start: 1, end: 5}},
+		},
+		{
+			name: "js functions and arrow functions",
+			path: "a.ts",
+			src: `export function add(a, b) {
+  return a + b;
+}
+
+export const mul = (a: number, b: number): number => {
+  return a * b;
+};
+
+const x = 1
+const cb = async () => {
+  if (x) {
+    await y();
+  }
+};
+
+class A {
+  async load(id) {
+    return id;
+  }
+}
+`,
+			want: []function{
+				{name: "add", start: 0, end: 2},
+				{name: "mul", start: 4, end: 6},
+				{name: "cb", start: 9, end: 13},
+				{name: "load", start: 16, end: 18},
+			},
+		},
+		{
+			name: "rust",
+			path: "a.rs",
+			src: `fn main() {
+    println!("{}", add(1, 2));
+}
+
+pub fn add(a: i32, b: i32) -> i32 { a + b }
+`,
+			want: []function{
+				{name: "main", start: 0, end: 2},
+				{name: "add", start: 4, end: 4},
+			},
+		},
+		{
+			name: "unknown language",
+			path: "README.md",
+			src:  "# gb\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findFunctions(tt.path, strings.Split(tt.src, "\n"))
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findFunctions =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionRange(t *testing.T) {
+	dir, _ := testRepo(t, "Add feature")
+	src := `class A:
+    def __init__(self):
+        pass
+
+
+class B:
+    def __init__(self):
+        self.a = A()
+        self.b = 1
+`
+	fp := filepath.Join(dir, "a.py")
+	if err := os.WriteFile(fp, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", "a.py")
+	git(t, dir, "commit", "-q", "-m", "Add a.py")
+	// the working tree version has B's constructor moved down.
+	if err := os.WriteFile(fp, []byte("# a\n\n"+src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		rev       string
+		fn        string
+		near      int
+		wantStart int
+		wantEnd   int
+		wantErr   string
+	}{
+		{name: "first of several", rev: "HEAD", fn: "__init__", near: 0, wantStart: 1, wantEnd: 2},
+		{name: "closest of several", rev: "HEAD", fn: "__init__", near: 5, wantStart: 6, wantEnd: 8},
+		{name: "working tree", fn: "__init__", near: 8, wantStart: 8, wantEnd: 10},
+		{name: "not found", rev: "HEAD", fn: "missing", wantErr: "no function missing in a.py"},
+		{name: "missing revision", rev: "HEAD~5", fn: "__init__", wantErr: "failed to read a.py"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := functionRange(fp, tt.rev, tt.fn, tt.near)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("functionRange = %d-%d, want %d-%d", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
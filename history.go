@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// lineChange is a commit of the history of a line range with its diff of the
+// range.
+type lineChange struct {
+	sha        string
+	author     string
+	authorTime time.Time
+	summary    string
+	// path is the file of the range after the commit relative to the
+	// repository root, "" if the commit removed it.
+	path string
+	// line is the first line of the range after the commit (0-based).
+	line int
+	diff string
+}
+
+// lineHistory returns the commits that changed the lines of spec, in the
+// -L syntax of git log, e.g. 3,5:main.go, from rev back, youngest first.
+func lineHistory(dir, spec, rev string) ([]lineChange, error) {
+	buf, err := runGit(dir, "log", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/",
+		"--format=%x1e%H%x1f%aN%x1f%at%x1f%s", "-L", spec, rev)
+	if err != nil {
+		return nil, err
+	}
+	return parseLineHistory(string(buf))
+}
+
+var rxHunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+func parseLineHistory(out string) ([]lineChange, error) {
+	res := []lineChange{}
+	for _, chunk := range strings.Split(out, "\x1e") {
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+		header, diff, _ := strings.Cut(chunk, "\n")
+		fields := strings.SplitN(header, "\x1f", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid log header %#v", header)
+		}
+		ts, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse author time of commit %s err=%w", fields[0], err)
+		}
+
+		ch := lineChange{
+			sha:        fields[0],
+			author:     fields[1],
+			authorTime: time.Unix(ts, 0),
+			summary:    fields[3],
+			diff:       strings.Trim(diff, "\n"),
+		}
+		// only the header block before the first hunk names the file, added
+		// lines may start with "++ " too.
+		for _, line := range strings.Split(ch.diff, "\n") {
+			if m := rxHunkHeader.FindStringSubmatch(line); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				ch.line = max(0, n-1)
+				break
+			}
+			if p, ok := strings.CutPrefix(line, "+++ "); ok {
+				ch.path = strings.TrimPrefix(p, "b/")
+				if p == "/dev/null" {
+					ch.path = ""
+				}
+			}
+		}
+		res = append(res, ch)
+	}
+	return res, nil
+}
+
+// showLineHistory shows the commits that changed the selected lines, the
+// current line or the function the blame is restricted to with their diffs
+// of the lines, selecting one blames the file at that commit.
+func (c *container) showLineHistory() {
+	if c.lineCount == 0 {
+		c.warn("file has no lines")
+		return
+	}
+	if c.data.opts.reverse {
+		c.warn("line history isn't available in reverse mode")
+		return
+	}
+	// c.data.path is the file at the blamed revision, relative to the root.
+	root, err := repoRoot(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get repository root err=%w", err))
+		return
+	}
+
+	start, end := c.selection()
+	rev := c.data.rev
+	if rev == "" {
+		rev = "HEAD"
+		if _, hasUncommitted := c.data.commits[uncommittedSHA]; hasUncommitted {
+			c.warn("file has uncommitted changes, lines may be off")
+		}
+	}
+
+	lines := lineRange(c.data.fileLine(start), c.data.fileLine(end))
+	spec := fmt.Sprintf("%d,%d:%s", c.data.fileLine(start)+1, c.data.fileLine(end)+1, c.data.path)
+	if fn := c.data.opts.function; fn != "" && !c.selecting {
+		// the blame holds the function's lines only.
+		lines = fn
+		spec = fmt.Sprintf("%d,%d:%s", c.data.fileLine(0)+1, c.data.fileLine(len(c.data.lines)-1)+1, c.data.path)
+	}
+	title := fmt.Sprintf(" %s:%s ", filepath.Base(c.filePath), lines)
+	c.info(fmt.Sprintf("loading history of %s", lines))
+	go func() {
+		changes, err := lineHistory(root, spec, rev)
+		if err != nil {
+			c.fail(fmt.Errorf("failed to get history of %s err=%w", lines, err))
+			return
+		}
+		c.app.QueueUpdateDraw(func() { c.showLineChanges(title, changes) })
+	}()
+}
+
+// showLineChanges lists changes above the diff of the selected change.
+func (c *container) showLineChanges(title string, changes []lineChange) {
+	if len(changes) == 0 {
+		c.warn("no history")
+		return
+	}
+
+	diff := tview.NewTextView().SetDynamicColors(true)
+	diff.
+		SetBorder(true).
+		SetBorderColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	diff.SetTextColor(tcell.ColorBlack.TrueColor())
+
+	list := newList(title).ShowSecondaryText(false)
+	for _, ch := range changes {
+		// commits in the blame show the unified author.
+		author, color := ch.author, tcell.ColorBlack
+		if cm, ok := c.data.commits[ch.sha]; ok {
+			author, color = cm.author.name, cm.author.color
+		}
+		list.AddItem(fmt.Sprintf("[#4CAF50]%s[#000000] [#2E7D32]%s [%s]%s[#000000]: %s",
+			ch.sha[:8], ch.authorTime.Format("2006-01-02"), color, tview.Escape(author), tview.Escape(ch.summary)), "", 0, nil)
+	}
+	list.SetChangedFunc(func(i int, _, _ string, _ rune) {
+		diff.SetText(colorizeDiff(changes[i].diff)).ScrollToBeginning()
+	})
+	list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		c.closeModal("history")
+		c.gotoLineChange(changes[i])
+	})
+	list.SetDoneFunc(func() { c.closeModal("history") })
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		row, _ := diff.GetScrollOffset()
+		_, _, _, height := diff.GetInnerRect()
+		switch event.Key() {
+		case tcell.KeyPgDn:
+			diff.ScrollTo(row+height/2, 0)
+			return nil
+		case tcell.KeyPgUp:
+			diff.ScrollTo(max(0, row-height/2), 0)
+			return nil
+		}
+		return event
+	})
+	diff.SetText(colorizeDiff(changes[0].diff))
+
+	flex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(list, min(len(changes), 8)+2, 0, true).
+		AddItem(diff, 0, 1, false)
+
+	_, _, width, height := c.pages.GetRect()
+	c.showModal("history", flex, max(40, width-10), max(16, height-6))
+}
+
+// gotoLineChange blames the file as of ch at the changed lines.
+func (c *container) gotoLineChange(ch lineChange) {
+	if ch.path == "" {
+		c.warn(fmt.Sprintf("%s removed the lines", ch.sha[:8]))
+		return
+	}
+	fp, err := repoFile(c.filePath, ch.path)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get repository root err=%w", err))
+		return
+	}
+	opts := c.blameOptions()
+	line := ch.line
+	if opts.function != "" {
+		// the blame starts at the function.
+		opts.funcStart, line = ch.line, 0
+	}
+	c.navigateTo(position{filePath: fp, rev: ch.sha, line: line, opts: opts})
+}
+
+// colorizeDiff escapes a diff for tview and colors its headers and added and
+// removed lines.
+func colorizeDiff(diff string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(diff, "\n") {
+		escaped := tview.Escape(line)
+		switch {
+		case strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
+			b.WriteString("[#9e9e9e]" + escaped + "[#000000]")
+		case strings.HasPrefix(line, "+"):
+			b.WriteString("[#2E7D32]" + escaped + "[#000000]")
+		case strings.HasPrefix(line, "-"):
+			b.WriteString("[#d32f2f]" + escaped + "[#000000]")
+		case strings.HasPrefix(line, "@@"):
+			b.WriteString("[#0277bd]" + escaped + "[#000000]")
+		default:
+			b.WriteString(escaped)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLineHistory(t *testing.T) {
+	out := "\x1e82b270dc3ea611882e909887ac61053fb06ce962\x1fBob\x1f1640995200\x1ftweak\n" +
+		"\n" +
+		"diff --git a/new.go b/new.go\n" +
+		"--- a/new.go\n" +
+		"+++ b/new.go\n" +
+		"@@ -8,2 +9,3 @@\n" +
+		" func b() {\n" +
+		"-\tprintln(1)\n" +
+		"++++ b/other.go\n" +
+		"+++ b/notes.txt\n" +
+		"\x1eb2427790815c1773e79b02701560f2c4e4c0cd7b\x1fAlice\x1f1420070400\x1finitial\n" +
+		"\n" +
+		"diff --git a/old.go b/old.go\n" +
+		"--- /dev/null\n" +
+		"+++ b/old.go\n" +
+		"@@ -0,0 +1,2 @@\n" +
+		"+func b() {\n" +
+		"+\tprintln(1)\n" +
+		"\x1ed9cf45a0\x1fAlice\x1f1420070400\x1fremove gone\n" +
+		"\n" +
+		"diff --git a/gone.go b/gone.go\n" +
+		"--- a/gone.go\n" +
+		"+++ /dev/null\n" +
+		"@@ -1,1 +0,0 @@\n" +
+		"-gone\n"
+
+	changes, err := parseLineHistory(out)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []struct {
+		sha     string
+		author  string
+		time    time.Time
+		summary string
+		path    string
+		line    int
+	}{
+		{sha: "82b270dc3ea611882e909887ac61053fb06ce962", author: "Bob", time: time.Unix(1640995200, 0), summary: "tweak", path: "new.go", line: 8},
+		{sha: "b2427790815c1773e79b02701560f2c4e4c0cd7b", author: "Alice", time: time.Unix(1420070400, 0), summary: "initial", path: "old.go", line: 0},
+		{sha: "d9cf45a0", author: "Alice", time: time.Unix(1420070400, 0), summary: "remove gone", path: "", line: 0},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d", len(changes), len(want))
+	}
+	for i, w := range want {
+		ch := changes[i]
+		if ch.sha != w.sha || ch.author != w.author || !ch.authorTime.Equal(w.time) || ch.summary != w.summary || ch.path != w.path || ch.line != w.line {
+			t.Errorf("change %d = %+v, want %+v", i, ch, w)
+		}
+	}
+	if changes[0].diff[:len("diff --git")] != "diff --git" {
+		t.Errorf("diff should start at the diff header, got %#v", changes[0].diff)
+	}
+}
+
+func TestParseLineHistoryInvalid(t *testing.T) {
+	_, err := parseLineHistory("\x1e82b270dc\x1fBob\n")
+	if err == nil {
+		t.Errorf("expected error for incomplete header")
+	}
+}
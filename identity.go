@@ -0,0 +1,153 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// identity is a name and email an author committed as.
+type identity struct {
+	name  string
+	email string
+}
+
+// unifyAuthors makes commits by the same person share one author.
+//
+// git applies .mailmap to blame output already, this catches the identities
+// it doesn't map via canonicalIdentities. The author's name and email are the
+// ones used in most commits.
+func unifyAuthors(commits map[string]*commit) {
+	counts := map[identity]int{}
+	for _, cm := range commits {
+		if cm.sha == uncommittedSHA {
+			continue
+		}
+		counts[identity{name: cm.author.name, email: cm.author.email}] += 1
+	}
+
+	canonical := canonicalIdentities(counts, nil)
+	members := map[identity][]identity{}
+	for id, c := range canonical {
+		members[c] = append(members[c], id)
+	}
+
+	authors := map[identity]*author{}
+	for c, ids := range members {
+		sort.Slice(ids, func(i, j int) bool { return identityLess(ids[i], ids[j], counts) })
+		authors[c] = &author{name: c.name, email: c.email, identities: ids}
+	}
+
+	for _, cm := range commits {
+		if c, ok := canonical[identity{name: cm.author.name, email: cm.author.email}]; ok {
+			cm.author = authors[c]
+		}
+	}
+}
+
+// canonicalIdentities maps each identity of weights to the identity of its
+// person: identities are the same person if they share an email, ignoring
+// case, or if they're in the same group of links. Names aren't compared as
+// different people may share one, .mailmap maps those that belong together.
+// A person's identity is the one with the most weight.
+func canonicalIdentities(weights map[identity]int, links [][]identity) map[identity]identity {
+	ids := []identity{}
+	index := map[identity]int{}
+	add := func(id identity) int {
+		i, ok := index[id]
+		if !ok {
+			i = len(ids)
+			index[id] = i
+			ids = append(ids, id)
+		}
+		return i
+	}
+	for id := range weights {
+		add(id)
+	}
+	for _, group := range links {
+		for _, id := range group {
+			add(id)
+		}
+	}
+
+	uf := newUnionFind(len(ids))
+	for _, group := range links {
+		if len(group) == 0 {
+			continue
+		}
+		for _, id := range group[1:] {
+			uf.union(index[id], index[group[0]])
+		}
+	}
+	byEmail := map[string]int{}
+	for i, id := range ids {
+		email := strings.ToLower(id.email)
+		if email == "" {
+			continue
+		}
+		if j, ok := byEmail[email]; ok {
+			uf.union(i, j)
+		} else {
+			byEmail[email] = i
+		}
+	}
+
+	best := map[int]identity{}
+	for i, id := range ids {
+		root := uf.find(i)
+		if b, ok := best[root]; !ok || identityLess(id, b, weights) {
+			best[root] = id
+		}
+	}
+
+	res := map[identity]identity{}
+	for i, id := range ids {
+		res[id] = best[uf.find(i)]
+	}
+	return res
+}
+
+// identityLess orders identities by descending weight, then name and email.
+func identityLess(a, b identity, weights map[identity]int) bool {
+	if weights[a] != weights[b] {
+		return weights[a] > weights[b]
+	}
+	if a.name != b.name {
+		return a.name < b.name
+	}
+	return a.email < b.email
+}
+
+// matches reports whether any of the author's names or emails contains query,
+// ignoring case.
+func (a *author) matches(query string) bool {
+	query = strings.ToLower(query)
+	for _, id := range a.identities {
+		if strings.Contains(strings.ToLower(id.name), query) || strings.Contains(strings.ToLower(id.email), query) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(a.name), query)
+}
+
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	uf := make(unionFind, n)
+	for i := range uf {
+		uf[i] = i
+	}
+	return uf
+}
+
+func (uf unionFind) find(i int) int {
+	for uf[i] != i {
+		uf[i] = uf[uf[i]]
+		i = uf[i]
+	}
+	return i
+}
+
+func (uf unionFind) union(i, j int) {
+	uf[uf.find(i)] = uf.find(j)
+}
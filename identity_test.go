@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalIdentities(t *testing.T) {
+	alice := identity{name: "Alice", email: "alice@example.com"}
+	aliceWork := identity{name: "Alice Smith", email: "ALICE@example.com"}
+	aliceOther := identity{name: "alice", email: "alice@other.com"}
+	bob := identity{name: "Bob", email: "bob@example.com"}
+	bobby := identity{name: "Bobby", email: "bobby@example.com"}
+	noEmail := identity{name: "Carol"}
+	noEmail2 := identity{name: "Dave"}
+
+	tests := []struct {
+		name    string
+		weights map[identity]int
+		links   [][]identity
+		want    map[identity]identity
+	}{
+		{
+			name:    "same email ignoring case",
+			weights: map[identity]int{alice: 1, aliceWork: 3, bob: 2},
+			want:    map[identity]identity{alice: aliceWork, aliceWork: aliceWork, bob: bob},
+		},
+		{
+			name:    "same name isn't the same person",
+			weights: map[identity]int{alice: 2, aliceOther: 1},
+			want:    map[identity]identity{alice: alice, aliceOther: aliceOther},
+		},
+		{
+			name:    "empty emails aren't shared",
+			weights: map[identity]int{noEmail: 1, noEmail2: 1},
+			want:    map[identity]identity{noEmail: noEmail, noEmail2: noEmail2},
+		},
+		{
+			name:    "ties break on name",
+			weights: map[identity]int{bob: 1, bobby: 1},
+			links:   [][]identity{{bobby, bob}},
+			want:    map[identity]identity{bob: bob, bobby: bob},
+		},
+		{
+			name:    "links join groups transitively",
+			weights: map[identity]int{alice: 1, aliceWork: 1, aliceOther: 5},
+			links:   [][]identity{{aliceOther, alice}, {}},
+			want:    map[identity]identity{alice: aliceOther, aliceWork: aliceOther, aliceOther: aliceOther},
+		},
+		{
+			name:    "linked identities without weight",
+			weights: map[identity]int{bob: 1},
+			links:   [][]identity{{bob, bobby}},
+			want:    map[identity]identity{bob: bob, bobby: bob},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := canonicalIdentities(tt.weights, tt.links)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("canonicalIdentities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifyAuthors(t *testing.T) {
+	newCommit := func(sha, name, email string) *commit {
+		return &commit{sha: sha, author: &author{name: name, email: email}}
+	}
+	commits := map[string]*commit{
+		"a1": newCommit("a1", "Alice", "alice@example.com"),
+		"a2": newCommit("a2", "Alice Smith", "alice@example.com"),
+		"a3": newCommit("a3", "Alice Smith", "alice@example.com"),
+		"a4": newCommit("a4", "Alice Smith", "Alice@Example.com"),
+		"b1": newCommit("b1", "Alice", "alice@other.com"),
+		"u":  newCommit(uncommittedSHA, "Not Committed Yet", "not.committed.yet"),
+	}
+	unifyAuthors(commits)
+
+	a := commits["a1"].author
+	if a.name != "Alice Smith" || a.email != "alice@example.com" {
+		t.Errorf("author = %s <%s>, want the most used Alice Smith <alice@example.com>", a.name, a.email)
+	}
+	for _, sha := range []string{"a2", "a3", "a4"} {
+		if commits[sha].author != a {
+			t.Errorf("commit %s author = %+v, want shared %+v", sha, commits[sha].author, a)
+		}
+	}
+	wantIDs := []identity{
+		{name: "Alice Smith", email: "alice@example.com"},
+		{name: "Alice", email: "alice@example.com"},
+		{name: "Alice Smith", email: "Alice@Example.com"},
+	}
+	if !reflect.DeepEqual(a.identities, wantIDs) {
+		t.Errorf("identities = %v, want %v", a.identities, wantIDs)
+	}
+
+	if b := commits["b1"].author; b == a || b.email != "alice@other.com" {
+		t.Errorf("author of other email = %+v, want separate from %+v", b, a)
+	}
+	if u := commits["u"].author; u.name != "Not Committed Yet" || len(u.identities) != 0 {
+		t.Errorf("uncommitted author = %+v, want it unchanged", u)
+	}
+}
+
+func TestAuthorMatches(t *testing.T) {
+	a := &author{name: "Alice Smith", identities: []identity{{name: "Alice", email: "alice@example.com"}, {name: "asmith", email: "asmith@work.com"}}}
+	for query, want := range map[string]bool{"smith": true, "ASMITH@": true, "work.com": true, "bob": false} {
+		if got := a.matches(query); got != want {
+			t.Errorf("matches(%#v) = %v, want %v", query, got, want)
+		}
+	}
+}
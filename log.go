@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelWarn
+	levelError
+	levelGit
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelWarn:
+		return "warn"
+	case levelError:
+		return "error"
+	case levelGit:
+		return "git"
+	}
+	return "info"
+}
+
+func (l logLevel) color() string {
+	switch l {
+	case levelWarn:
+		return "#e54304"
+	case levelError:
+		return "#ff5544"
+	case levelGit:
+		return "#9e9e9e"
+	}
+	return "#000000"
+}
+
+type logEntry struct {
+	time  time.Time
+	level logLevel
+	msg   string
+}
+
+func (e logEntry) String() string {
+	return fmt.Sprintf("%s %-5s %s", e.time.Format("15:04:05.000"), e.level, e.msg)
+}
+
+// messageLog collects timestamped diagnostic messages: warnings, errors and
+// the git commands that were run.
+type messageLog struct {
+	sync.Mutex
+	entries []logEntry
+	file    io.WriteCloser
+
+	// onAdd is called after an entry was added, without holding the lock.
+	onAdd func()
+}
+
+// messages is the log that all diagnostic messages are sent to.
+var messages = &messageLog{}
+
+func (l *messageLog) add(level logLevel, format string, args ...any) {
+	e := logEntry{time: time.Now(), level: level, msg: fmt.Sprintf(format, args...)}
+
+	l.Lock()
+	l.entries = append(l.entries, e)
+	if l.file != nil {
+		fmt.Fprintln(l.file, e)
+	}
+	onAdd := l.onAdd
+	l.Unlock()
+
+	if onAdd != nil {
+		onAdd()
+	}
+}
+
+func (l *messageLog) all() []logEntry {
+	l.Lock()
+	defer l.Unlock()
+	return append([]logEntry{}, l.entries...)
+}
+
+// openFile additionally appends all messages to the file at path.
+func (l *messageLog) openFile(path string) error {
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.file = fh
+	for _, e := range l.entries {
+		fmt.Fprintln(fh, e)
+	}
+	return nil
+}
+
+// closeFile closes the file opened via openFile.
+func (l *messageLog) closeFile() error {
+	l.Lock()
+	defer l.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
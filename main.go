@@ -1,14 +1,15 @@
 package main
 
 import (
-	"crypto/sha1"
+	"errors"
+	"flag"
 	"fmt"
 	"hash/fnv"
-	"net/url"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
@@ -20,32 +21,101 @@ import (
 )
 
 func main() {
-	if len(os.Args) != 2 {
+	subcommands := map[string]func([]string, io.Writer, io.Writer) error{
+		"report": runReport,
+		"owners": runOwners,
+		"export": runExport,
+	}
+	if len(os.Args) > 1 && subcommands[os.Args[1]] != nil {
+		err := subcommands[os.Args[1]](os.Args[2:], os.Stdout, os.Stderr)
+		// like -h for gb itself, asking for help isn't an error.
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gb [flags] file|rev:path...")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gb report [flags] [path...]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gb owners [flags] [path...]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gb export [flags] file|rev:path")
+		flag.PrintDefaults()
+	}
+	logFile := flag.String("log-file", "", "append diagnostic messages to this file")
+	since := flag.String("since", "", "attribute lines only to commits more recent than this date, e.g. 3.months or 2024-01-31")
+	revRange := flag.String("range", "", "attribute lines only to commits in the revision range A..B, B defaults to the revision of a rev:path argument or the working tree")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		fmt.Println("file path is a required argument")
 		os.Exit(1)
 	}
-	filePath := os.Args[1]
 
-	fh, err := os.OpenFile(filePath, os.O_RDONLY, 0)
-	if err != nil {
-		fmt.Printf("can't open given file %#v\n", filePath)
-		os.Exit(1)
+	opts := blameOptions{since: *since}
+	rangeEnd := ""
+	if *revRange != "" {
+		var err error
+		opts.boundary, rangeEnd, err = parseRange(*revRange)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	tabs := []*tab{}
+	for _, arg := range flag.Args() {
+		t, err := newTab(arg)
+		if err != nil {
+			fmt.Printf("can't open given file %#v err=%v\n", arg, err)
+			os.Exit(1)
+		}
+		t.opts = opts
+		// with A.. the revision of a rev:path argument ends the range.
+		if rangeEnd != "" && t.startRev != "" && t.startRev != rangeEnd {
+			fmt.Printf("range %#v conflicts with the revision of %#v, use %s.. instead\n", *revRange, arg, opts.boundary)
+			os.Exit(1)
+		}
+		if t.startRev == "" {
+			t.startRev = rangeEnd
+		}
+		tabs = append(tabs, t)
+	}
+
+	// messages logged so far are written when opening the file.
+	if *logFile != "" {
+		err := messages.openFile(*logFile)
+		if err != nil {
+			fmt.Printf("can't open log file %#v err=%v\n", *logFile, err)
+			os.Exit(1)
+		}
 	}
 
-	err = fh.Close()
+	err := new().run(tabs)
+	messages.closeFile()
 	if err != nil {
-		fmt.Println("failed to close the file")
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+}
 
-	new().run(filePath)
+// parseRange splits the revision range A..B, B is "" for the working tree.
+func parseRange(r string) (string, string, error) {
+	boundary, end, ok := strings.Cut(r, "..")
+	if !ok || boundary == "" || strings.HasPrefix(end, ".") {
+		return "", "", fmt.Errorf("invalid range %#v, expected A..B or A..", r)
+	}
+	return boundary, end, nil
 }
 
 func new() *container {
 	c := container{
 		app:     tview.NewApplication(),
-		chBlame: make(chan *blameData),
-		log:     []string{},
+		chBlame: make(chan blameResult),
 	}
 	return &c
 }
@@ -53,22 +123,28 @@ func new() *container {
 type container struct {
 	app *tview.Application
 
+	pages       *tview.Pages
 	fileView    *tview.TextView
 	infoView    *tview.Table
 	logView     *tview.Table
+	statsView   *tview.Table
 	lineNumbers *tview.TextView
 	menubar     *tview.TextView
 	titlebar    *tview.TextView
+	legendBar   *tview.TextView
+	tabbar      *tview.TextView
+	messageView *tview.TextView
 	flexRoot    *tview.Flex
 	flexMain    *tview.Flex
 
-	filePath      string
-	data          *blameData
-	lineCount     int
-	revListDesc   []string
-	githubBaseURL string
+	// tab is the active one of tabs.
+	*tab
+	tabs []*tab
+
+	remotes    []remote
+	remoteName string
+	forge      forge
 
-	currentLine        int
 	readingLineNumber  *string
 	readingSearchQuery *string
 
@@ -76,11 +152,30 @@ type container struct {
 	searchQuery string
 	matchCount  int
 
-	chBlame chan *blameData
-	log     []string
+	showMessages bool
+
+	showStats bool
+	// highlighted marks the lines of matching commits in the file view.
+	highlighted func(*commit) bool
+
+	colorMode colorMode
+	// gutter shows a stripe in the colors of the line's commit next to the
+	// line numbers.
+	gutter bool
+
+	chBlame chan blameResult
+
+	// lastErr is shown in the menubar until the next key press.
+	lastErr error
+	// err is the fatal error that stopped the application, returned by run.
+	err error
 }
 
 func (c *container) menuContent() string {
+	if c.lastErr != nil {
+		return fmt.Sprintf("[#ff5544]error: %s[#000000]", tview.Escape(c.lastErr.Error()))
+	}
+
 	if c.data == nil {
 		return ""
 	}
@@ -112,16 +207,46 @@ func (c *container) menuContent() string {
 		return b.String()
 	}
 
+	if c.selecting {
+		start, end := c.selection()
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("selection: [#e54304]%s[#000000] - ", lineRange(start, end)))
+		keys := []key{
+			{code: "⏎", descr: "summary"},
+			{code: "L", descr: "history"},
+			{code: "p", descr: "permalink"},
+			{code: "y", descr: "copy"},
+			{code: "ESC", descr: "cancel"},
+		}
+		for _, k := range keys {
+			str := fmt.Sprintf(
+				"[#4CAF50]%s[#000000] [#000000]%s[#000000] ",
+				k.code,
+				k.descr,
+			)
+			b.WriteString(str)
+		}
+		return b.String()
+	}
+
 	var b strings.Builder
 	keys := []key{
 		{code: "↑↓", descr: "scroll"},
 		{code: "<>", descr: "file rev"},
 		{code: "b a", descr: "before/after line rev"},
 		{code: "l", descr: "commit summary"},
-		{code: "g", descr: "open gh pr"},
+		{code: "g o p d", descr: "open pr/commit/file/diff"},
+		{code: "y", descr: "copy"},
+		{code: "e", descr: "edit"},
+		{code: "t", descr: "open file"},
+		{code: "⇥ ⇤ x", descr: "next/previous/close tab"},
 		{code: "/", descr: "search"},
-		{code: "ESC", descr: "quit"},
+		{code: "m", descr: "messages"},
+	}
+	if len(c.forgeRemotes()) > 1 {
+		keys = append(keys, key{code: "R", descr: "remote"})
 	}
+	keys = append(keys, key{code: "ESC", descr: "quit"})
 	for _, k := range keys {
 		str := fmt.Sprintf(
 			"[#4CAF50]%s[#000000] [#000000]%s[#000000] ",
@@ -134,8 +259,9 @@ func (c *container) menuContent() string {
 	return b.String()
 }
 
-func (c *container) run(filePath string) {
-	c.filePath = filePath
+func (c *container) run(tabs []*tab) error {
+	c.tabs = tabs
+	c.tab = c.tabs[0]
 
 	c.fileView = tview.NewTextView().
 		SetDynamicColors(true).
@@ -159,6 +285,21 @@ func (c *container) run(filePath string) {
 	c.logView.
 		SetBackgroundColor(tcell.ColorWhite.TrueColor())
 
+	c.statsView = tview.NewTable().
+		SetSelectable(true, false).
+		SetSelectedStyle(tcell.StyleDefault.
+			Foreground(tcell.ColorBlack.TrueColor()).
+			Background(tcell.GetColor("#e8ecf0").TrueColor()))
+	c.statsView.
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	c.statsView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 's' {
+			c.toggleStats()
+			return nil
+		}
+		return event
+	})
+
 	c.menubar = tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(false)
@@ -175,6 +316,22 @@ func (c *container) run(filePath string) {
 		SetBackgroundColor(tcell.GetColor("#e8ecf0").TrueColor())
 	c.titlebar.SetText(filepath.Base(c.filePath))
 
+	c.legendBar = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(false).
+		SetWrap(false)
+	c.legendBar.
+		SetTextColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+	c.tabbar = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(false).
+		SetWrap(false)
+	c.tabbar.
+		SetTextColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
 	c.lineNumbers = tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true)
@@ -188,94 +345,242 @@ func (c *container) run(filePath string) {
 		AddItem(c.infoView, 0, 1, true).
 		AddItem(c.logView, 0, 2, true)
 
+	c.messageView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	c.messageView.
+		SetTextColor(tcell.ColorBlack.TrueColor()).
+		SetBackgroundColor(tcell.GetColor("#f5f5f5").TrueColor())
+	messages.onAdd = func() {
+		// messages are added from goroutines as well as the event loop.
+		go c.app.QueueUpdateDraw(func() {
+			if c.showMessages {
+				c.renderMessages()
+			}
+		})
+	}
+
 	c.flexRoot = tview.NewFlex().
 		SetDirection(tview.FlexRow).
+		AddItem(c.tabbar, 0, 0, false).
 		AddItem(c.titlebar, 1, 1, false).
+		AddItem(c.legendBar, 1, 1, false).
 		AddItem(c.flexMain, 0, 1, true).
+		AddItem(c.messageView, 0, 0, false).
 		AddItem(c.menubar, 1, 1, false)
 
-	c.app.SetRoot(c.flexRoot, true)
+	c.pages = tview.NewPages().
+		AddPage("main", c.flexRoot, true, true)
 
-	go func() {
-		var err error
-		c.revListDesc, err = c.revList(filePath)
-		if err != nil {
-			fmt.Println("failed to get rev list")
-			os.Exit(1)
-		}
+	c.app.SetRoot(c.pages, true)
 
-		c.setGithubBaseURL(filePath)
+	c.renderTabbar()
 
-		out, err := blame(filePath, "")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to get initial blame output err=%v\n", err)
-			os.Exit(1)
+	go func() {
+		c.setRemotes(c.filePath)
+		for _, t := range c.tabs {
+			go c.load(t, c.fatal)
 		}
-		c.chBlame <- out
 	}()
 	go func() { c.receive() }()
 
 	c.setKeys()
 	err := c.app.Run()
+	messages.onAdd = nil
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		return err
+	}
+
+	return c.err
+}
+
+// fatal stops the application, restoring the terminal, and makes run return
+// err. It must not be called from the event loop.
+func (c *container) fatal(err error) {
+	c.app.QueueUpdate(func() {
+		c.err = err
+		c.app.Stop()
+	})
+}
+
+// fail records err and shows it in the menubar until the next key press.
+func (c *container) fail(err error) {
+	messages.add(levelError, "%v", err)
+	// fail is called from goroutines as well as the event loop, which would
+	// block queueing the update if its queue is full.
+	go c.app.QueueUpdateDraw(func() {
+		c.lastErr = err
+		c.menubar.SetText(c.menuContent())
+	})
+}
+
+// lineCommit returns the commit of the current line, nil if there is none.
+func (c *container) lineCommit() *commit {
+	if c.data == nil {
+		return nil
 	}
+	return c.data.lineCommits[c.currentLine]
+}
+
+// youngestCommit returns the most recent commit of the current blame, nil if
+// the blame has no committed lines.
+func (c *container) youngestCommit() *commit {
+	if c.data == nil || len(c.data.sortedCommits) == 0 {
+		return nil
+	}
+	return c.data.sortedCommits[0]
+}
+
+// blameResult is a finished blame of filePath to show in tab.
+type blameResult struct {
+	tab      *tab
+	filePath string
+	// revList replaces the tab's revision list unless it's nil.
+	revList []revision
+	data    *blameData
+	// line is the line to move to once the blame is shown.
+	line int
 }
 
 func (c *container) receive() {
 	for {
 		select {
-		case out := <-c.chBlame:
-			c.data = out
-			c.lineCount = len(out.lines)
-			c.currentLine = 0
-
-			title := filepath.Base(c.filePath)
-			youngestRev := c.data.sortedCommits[0]
-			title += fmt.Sprintf(" @ [%s]%s[#000000]: %s", youngestRev.color, youngestRev.sha[:8], youngestRev.summary)
-			c.titlebar.SetText(title)
-
-			maxAuthorLen := 0
-			for _, c := range out.sortedCommits {
-				if len(c.author.name) > maxAuthorLen {
-					maxAuthorLen = len(c.author.name)
+		case res := <-c.chBlame:
+			c.app.QueueUpdateDraw(func() {
+				t := res.tab
+				t.filePath = res.filePath
+				if res.revList != nil {
+					t.revListDesc = res.revList
+				}
+				t.data = res.data
+				t.lineCount = len(res.data.lines)
+				t.selecting = false
+				t.currentLine = 0
+				if res.line > 0 && res.line < t.lineCount {
+					t.currentLine = res.line
 				}
-			}
 
-			lineCount := fmt.Sprintf("%v", len(c.data.lines))
+				if t == c.tab {
+					c.showTab()
+				}
+			})
+		}
+	}
+}
 
-			c.infoView.Clear()
-			for i := range out.lines {
-				cm := out.lineCommits[i]
-				paddedAuthor := cm.author.name + strings.Repeat(" ", maxAuthorLen-len(cm.author.name))
+// showTab renders the active tab.
+func (c *container) showTab() {
+	c.renderTabbar()
+	c.menubar.SetText(c.menuContent())
+	c.infoView.Clear()
+	c.logView.Clear()
 
-				author := tview.NewTableCell(paddedAuthor).
-					SetTextColor(cm.author.color.TrueColor()).
-					SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	if c.data == nil {
+		c.titlebar.SetText(filepath.Base(c.filePath))
+		c.renderLegend()
+		c.fileView.SetText("Loading...")
+		c.lineNumbers.SetText("")
+		return
+	}
 
-				authorTime := tview.NewTableCell(cm.authorTime.Format("2006-01-02")).
-					SetTextColor(tcell.GetColor("#2E7D32").TrueColor()).
-					SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	out := c.data
+	colorCommits(out, c.colorMode, time.Now())
+	c.renderLegend()
 
-				sha := tview.NewTableCell(cm.sha[:8]).
-					SetTextColor(cm.color.TrueColor()).
-					SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	title := filepath.Base(c.filePath)
+	if len(c.revListDesc) > 0 && c.revListDesc[0].path != out.path {
+		title += fmt.Sprintf(" [#9e9e9e](renamed to %s)[#000000]", tview.Escape(c.revListDesc[0].path))
+	}
+	if out.opts.reverse {
+		title += fmt.Sprintf(" [#ee6002]reverse %s..HEAD[#000000]", tview.Escape(shortRev(out.rev)))
+	} else {
+		if out.opts.boundary != "" {
+			title += fmt.Sprintf(" [#9e9e9e]range %s..%s[#000000]", tview.Escape(out.opts.boundary), tview.Escape(shortRev(out.rev)))
+		}
+		if out.opts.since != "" {
+			title += fmt.Sprintf(" [#9e9e9e]since %s[#000000]", tview.Escape(out.opts.since))
+		}
+	}
+	if out.opts.function != "" {
+		title += fmt.Sprintf(" [#9e9e9e]function %s[#000000]", tview.Escape(out.opts.function))
+	}
+	if youngestRev := c.youngestCommit(); youngestRev != nil {
+		title += fmt.Sprintf(" @ [%s]%s[#000000]: %s", youngestRev.color, youngestRev.sha[:8], tview.Escape(youngestRev.summary))
+	}
+	c.titlebar.SetText(title)
 
-				c.infoView.SetCell(i, 0, author)
-				c.infoView.SetCell(i, 1, authorTime)
-				c.infoView.SetCell(i, 2, sha)
-			}
+	maxAuthorLen := 0
+	for _, c := range out.lineCommits {
+		if len(c.author.name) > maxAuthorLen {
+			maxAuthorLen = len(c.author.name)
+		}
+	}
+
+	maxOriginLen := 0
+	origins := make([]string, len(out.lines))
+	for i, o := range out.origins {
+		if o.path == out.path {
+			continue
+		}
+		// reverse blames attribute lines to where they were last seen.
+		arrow := "←"
+		if out.opts.reverse {
+			arrow = "→"
+		}
+		origins[i] = fmt.Sprintf("%s %s:%d", arrow, path.Base(o.path), o.line+1)
+		maxOriginLen = max(maxOriginLen, len([]rune(origins[i])))
+	}
 
-			c.flexMain.ResizeItem(c.infoView, maxAuthorLen+1+10+1+8, 3)
-			c.flexMain.ResizeItem(c.lineNumbers, len(lineCount)+2, 1)
-			c.flexMain.ResizeItem(c.logView, 43, 4)
+	lineCount := fmt.Sprintf("%v", c.data.fileLine(len(c.data.lines)-1)+1)
 
-			c.scrollTo(0)
-			c.menubar.SetText(c.menuContent())
-			c.app.Draw()
+	for i := range out.lines {
+		cm := out.lineCommits[i]
+		paddedAuthor := cm.author.name + strings.Repeat(" ", maxAuthorLen-len(cm.author.name))
+
+		authorColor, timeColor, shortSHA := cm.author.color, tcell.GetColor("#2E7D32"), cm.sha[:8]
+		if cm.boundary {
+			authorColor, timeColor, shortSHA = tcell.GetColor(boundaryColor), tcell.GetColor(boundaryColor), "^"+cm.sha[:7]
 		}
+
+		author := tview.NewTableCell(paddedAuthor).
+			SetTextColor(authorColor.TrueColor()).
+			SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+		authorTime := tview.NewTableCell(cm.authorTime.Format("2006-01-02")).
+			SetTextColor(timeColor.TrueColor()).
+			SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+		sha := tview.NewTableCell(shortSHA).
+			SetTextColor(cm.color.TrueColor()).
+			SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+		origin := tview.NewTableCell(tview.Escape(origins[i])).
+			SetTextColor(tcell.GetColor("#9e9e9e").TrueColor()).
+			SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+		c.infoView.SetCell(i, 0, author)
+		c.infoView.SetCell(i, 1, authorTime)
+		c.infoView.SetCell(i, 2, sha)
+		c.infoView.SetCell(i, 3, origin)
+	}
+
+	infoWidth := maxAuthorLen + 1 + 10 + 1 + 8
+	if maxOriginLen > 0 {
+		infoWidth += 1 + maxOriginLen
+	}
+	c.flexMain.ResizeItem(c.infoView, infoWidth, 3)
+	gutterWidth := len(lineCount) + 2
+	if c.gutter {
+		gutterWidth += 1
+	}
+	c.flexMain.ResizeItem(c.lineNumbers, gutterWidth, 1)
+	c.flexMain.ResizeItem(c.logView, 43, 4)
+	if c.showStats {
+		c.renderStats()
 	}
+
+	c.gotoLine(c.currentLine)
 }
 
 func (c *container) render() {
@@ -286,15 +591,10 @@ func (c *container) render() {
 func (c *container) renderLogContent(selectedCommit *commit) {
 	c.logView.Clear()
 
-	currentLineCommit := c.data.lineCommits[c.currentLine]
-	if currentLineCommit == nil {
-		c.log = append(c.log, "current line commit not found")
-		return
-	}
-
-	lineCommit := c.data.lineCommits[c.currentLine]
+	// e.g. empty files have no line commits, this renders on every key press
+	// so it isn't logged.
+	lineCommit := c.lineCommit()
 	if lineCommit == nil {
-		c.log = append(c.log, fmt.Sprintf("failed to find commit for line %v", c.currentLine+1))
 		return
 	}
 
@@ -346,7 +646,7 @@ func (c *container) renderMainContent() {
 	}
 
 	c.matchCount = 0
-	lineCount := fmt.Sprintf("%v", len(c.data.lines))
+	lineCount := fmt.Sprintf("%v", c.data.fileLine(len(c.data.lines)-1)+1)
 	var fileBuilder strings.Builder
 	var lineBuilder strings.Builder
 	colCount := c.infoView.GetColumnCount()
@@ -358,7 +658,7 @@ func (c *container) renderMainContent() {
 		}
 		escaped := tview.Escape(line)
 
-		if c.searchMode {
+		if c.searchMode && !c.isAuthorSearch() {
 			hasMatch := strings.Contains(escaped, c.searchQuery)
 			for {
 				if !strings.Contains(escaped, c.searchQuery) {
@@ -378,22 +678,44 @@ func (c *container) renderMainContent() {
 		}
 
 		// file view
-		if i == c.currentLine {
+		if i == c.currentLine || c.isSelected(i) {
+			bg := "#e8ecf0"
+			if c.isSelected(i) {
+				bg = selectedColor
+			}
 			padded := escaped
 			delta := width - renderedLen(line)
 			if delta > 0 {
 				padded += strings.Repeat(" ", delta)
 			}
-			fileBuilder.WriteString("[#000000:#e8ecf0]" + padded + "[#000000:#ffffff]")
+			fileBuilder.WriteString("[#000000:" + bg + "]" + padded + "[#000000:#ffffff]")
+		} else if cm := c.data.lineCommits[i]; cm != nil && c.highlighted != nil && c.highlighted(cm) {
+			padded := escaped
+			delta := width - renderedLen(line)
+			if delta > 0 {
+				padded += strings.Repeat(" ", delta)
+			}
+			fileBuilder.WriteString("[#000000:#fff3c4]" + padded + "[#000000:#ffffff]")
+		} else if cm != nil && cm.boundary {
+			fileBuilder.WriteString("[" + boundaryColor + "]" + escaped + "[#000000]")
 		} else {
 			fileBuilder.WriteString(escaped)
 		}
 
 		// line view
-		num := fmt.Sprintf("%v", i+1)
+		num := fmt.Sprintf("%v", c.data.fileLine(i)+1)
 		num = strings.Repeat(" ", len(lineCount)-len(num)) + num
 		num = " " + num + " "
-		if i == c.currentLine {
+		if c.gutter {
+			if cm := c.data.lineCommits[i]; cm != nil {
+				lineBuilder.WriteString(fmt.Sprintf("[%s:#ffffff]▐[#9e9e9e:#ffffff]", cm.color))
+			} else {
+				lineBuilder.WriteString(" ")
+			}
+		}
+		if c.isSelected(i) {
+			lineBuilder.WriteString("[#000000:" + selectedColor + "]" + num + "[#9e9e9e:#ffffff]")
+		} else if i == c.currentLine {
 			lineBuilder.WriteString("[#000000:#e8ecf0]" + num + "[#9e9e9e:#ffffff]")
 		} else {
 			lineBuilder.WriteString(num)
@@ -402,7 +724,9 @@ func (c *container) renderMainContent() {
 		// info view
 		for j := 0; j < colCount; j++ {
 			cell := c.infoView.GetCell(i, j)
-			if i == c.currentLine {
+			if c.isSelected(i) {
+				cell.SetBackgroundColor(tcell.GetColor(selectedColor).TrueColor())
+			} else if i == c.currentLine {
 				cell.SetBackgroundColor(tcell.NewRGBColor(0xe8, 0xec, 0xf0))
 			} else {
 				cell.SetBackgroundColor(tcell.ColorWhite.TrueColor())
@@ -412,7 +736,7 @@ func (c *container) renderMainContent() {
 	c.fileView.SetText(fileBuilder.String())
 	c.lineNumbers.SetText(lineBuilder.String())
 
-	if c.searchMode {
+	if c.searchMode && !c.isAuthorSearch() {
 		regionIDs := make([]string, 0, c.matchCount)
 		for i := 0; i < c.matchCount; i++ {
 			regionIDs = append(regionIDs, fmt.Sprintf("%v", i))
@@ -425,36 +749,25 @@ var (
 	scrollMargin = 3
 )
 
-func extractPullRequestReference(summary string) string {
-	rxPRRef := regexp.MustCompile(`#([0-9]+)`)
-	matches := rxPRRef.FindAllStringSubmatch(summary, -1)
-	if len(matches) == 1 && len(matches[0]) == 2 {
-		return matches[0][1]
-	}
-	return ""
-}
-
-func openURL(url string) {
+func openURL(url string) error {
 	switch runtime.GOOS {
 	case "darwin":
-		exec.Command("open", url).Run()
+		return exec.Command("open", url).Run()
 	case "linux":
-		exec.Command("xdg-open", url).Run()
+		return exec.Command("xdg-open", url).Run()
 	case "windows":
-		exec.Command("cmd.exe", "/C", "start", url).Run()
+		return exec.Command("cmd.exe", "/C", "start", url).Run()
 	}
+	return fmt.Errorf("opening urls is not supported on %s", runtime.GOOS)
 }
 
 func (c *container) stop() {
 	c.app.Stop()
-	for _, line := range c.log {
-		fmt.Println(line)
-	}
 }
 
 func (c *container) scrollDown() {
 	rowOffset, _ := c.fileView.GetScrollOffset()
-	c.currentLine = min(c.lineCount-1, c.currentLine+1)
+	c.currentLine = max(0, min(c.lineCount-1, c.currentLine+1))
 
 	_, _, _, height := c.fileView.GetInnerRect()
 	if c.currentLine >= rowOffset+height-scrollMargin {
@@ -473,9 +786,10 @@ func (c *container) scrollTo(offset int) {
 }
 
 func (c *container) scrollToLogEntry() {
-	lineCommit := c.data.lineCommits[c.currentLine]
+	// e.g. empty files have no line commits, this renders on every key press
+	// so it isn't logged.
+	lineCommit := c.lineCommit()
 	if lineCommit == nil {
-		c.log = append(c.log, fmt.Sprintf("failed to find commit for line %v", c.currentLine+1))
 		return
 	}
 
@@ -488,7 +802,7 @@ func (c *container) scrollToLogEntry() {
 		break
 	}
 	if offset == -1 {
-		c.log = append(c.log, fmt.Sprintf("failed to find offset for commit %#v", lineCommit.sha))
+		messages.add(levelWarn, "failed to find offset for commit %#v", lineCommit.sha)
 		return
 	}
 
@@ -510,17 +824,25 @@ func (c *container) gotoLine(nr int) {
 	c.currentLine = nr
 	_, _, _, height := c.fileView.GetInnerRect()
 
-	rowOffset := max(0, c.currentLine-(height/2))
-	rowOffset = min(c.lineCount-1, rowOffset)
+	rowOffset := min(c.lineCount-1, c.currentLine-(height/2))
+	rowOffset = max(0, rowOffset)
 
 	c.scrollTo(rowOffset)
 }
 
 func (c *container) setKeys() {
 	c.fileView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if c.lastErr != nil {
+			c.lastErr = nil
+			c.menubar.SetText(c.menuContent())
+		}
+
 		if c.searchMode {
 			switch event.Key() {
 			case tcell.KeyEscape, tcell.KeyCtrlC, tcell.KeyCR:
+				if c.isAuthorSearch() {
+					c.highlighted = nil
+				}
 				c.searchMode = false
 				c.readingSearchQuery = nil
 				c.searchQuery = ""
@@ -536,7 +858,18 @@ func (c *container) setKeys() {
 				}
 
 				key := event.Rune()
+				if c.isAuthorSearch() {
+					delta := 1
+					if key == 'p' {
+						delta = -1
+					}
+					c.gotoHighlighted(delta)
+					return nil
+				}
 				highlights := c.fileView.GetHighlights()
+				if c.matchCount == 0 || len(highlights) == 0 {
+					return nil
+				}
 				index, _ := strconv.Atoi(highlights[0])
 				if key == 'n' {
 					index = (index + 1) % c.matchCount
@@ -563,6 +896,11 @@ func (c *container) setKeys() {
 				c.searchQuery = *c.readingSearchQuery
 				c.readingSearchQuery = nil
 				c.menubar.SetText(c.menuContent())
+				if query, ok := strings.CutPrefix(c.searchQuery, "@"); ok {
+					c.highlighted = func(cm *commit) bool { return cm.author.matches(query) }
+					c.gotoHighlighted(0)
+					return nil
+				}
 				c.render()
 
 				return nil
@@ -592,15 +930,35 @@ func (c *container) setKeys() {
 			}
 		}
 
+		if c.selecting && event.Key() == tcell.KeyEscape {
+			c.clearSelection()
+			return nil
+		}
+
 		switch event.Key() {
 		case tcell.KeyEscape, tcell.KeyCtrlC:
 			c.stop()
 			return nil
+		case tcell.KeyTab:
+			c.nextTab(1)
+			return nil
+		case tcell.KeyBacktab:
+			c.nextTab(-1)
+			return nil
 		case tcell.KeyRune:
 			switch event.Rune() {
 			case 'q':
 				c.stop()
 				return nil
+			case 'm':
+				c.toggleMessages()
+				return nil
+			case 't':
+				c.chooseFile()
+				return nil
+			case 'x':
+				c.closeTab(c.tab)
+				return nil
 			}
 		}
 
@@ -611,14 +969,68 @@ func (c *container) setKeys() {
 
 		switch event.Key() {
 		case tcell.KeyDown:
+			if event.Modifiers()&tcell.ModShift != 0 {
+				c.extendSelection(1)
+				break
+			}
 			c.scrollDown()
 		case tcell.KeyUp:
+			if event.Modifiers()&tcell.ModShift != 0 {
+				c.extendSelection(-1)
+				break
+			}
 			c.scrollUp()
+		case tcell.KeyEnter:
+			if c.selecting {
+				c.showSelectionSummary()
+			}
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			c.back()
 
 		case tcell.KeyRune:
 			switch event.Rune() {
 			case 'g':
 				c.openPullRequest()
+			case 'o':
+				c.openCommit()
+			case 'p':
+				c.openPermalink(c.selection())
+			case 'v':
+				c.toggleSelection()
+			case 'L':
+				c.showLineHistory()
+			case 'f':
+				c.showFunctions()
+			case 'F':
+				c.toggleFunctionBlame()
+			case '[':
+				c.gotoFunction(-1)
+			case ']':
+				c.gotoFunction(1)
+			case 'd':
+				c.openCompare()
+			case 'y':
+				c.chooseCopy()
+			case 'e':
+				c.editCurrentLine()
+			case 'j':
+				c.followOrigin()
+			case 'r':
+				c.toggleReverse()
+			case 's':
+				c.toggleStats()
+			case 'O':
+				c.showOwners()
+			case 'E':
+				c.exportHTML()
+			case 'c':
+				c.cycleColorMode()
+			case 'C':
+				c.toggleGutter()
+			case 'D':
+				c.gotoRemoval()
+			case 'R':
+				c.chooseRemote()
 			case 'G':
 				c.gotoReadLine()
 			case 'l':
@@ -646,17 +1058,47 @@ func (c *container) setKeys() {
 	})
 }
 
+// isAuthorSearch reports whether the search query is an author, as in
+// @name or @email.
+func (c *container) isAuthorSearch() bool {
+	return c.searchMode && strings.HasPrefix(c.searchQuery, "@")
+}
+
+// gotoHighlighted moves to the next highlighted line in direction delta,
+// wrapping around, or to the first one from the current line if delta is 0.
+func (c *container) gotoHighlighted(delta int) {
+	if c.highlighted == nil || c.lineCount == 0 {
+		return
+	}
+
+	start := c.currentLine + delta
+	step := delta
+	if step == 0 {
+		step = 1
+	}
+	for n := 0; n < c.lineCount; n++ {
+		i := ((start+n*step)%c.lineCount + c.lineCount) % c.lineCount
+		if cm := c.data.lineCommits[i]; cm != nil && c.highlighted(cm) {
+			c.gotoLine(i)
+			return
+		}
+	}
+	c.render()
+	c.warn("no matching lines")
+}
+
 func (c *container) gotoReadLine() {
 	if c.readingLineNumber == nil {
 		return
 	}
 	i, err := strconv.Atoi(*c.readingLineNumber)
 	if err != nil {
-		c.log = append(c.log, fmt.Sprintf("failed to convert read line number err=%v", err))
+		messages.add(levelWarn, "failed to convert read line number err=%v", err)
 		return
 	}
+	i -= c.data.firstLine
 	if i < 1 || i > len(c.data.lines) {
-		c.log = append(c.log, fmt.Sprintf("read line number %#v is out of bunds", i))
+		messages.add(levelWarn, "read line number %#v is out of bunds", i)
 		return
 	}
 	c.gotoLine(i - 1)
@@ -674,258 +1116,841 @@ func (c *container) readLineNumber(rn rune) {
 }
 
 func (c *container) openPullRequest() {
-	cm := c.data.lineCommits[c.currentLine]
-	c.showLogSummary()
+	cm, ok := c.committedLineCommit()
+	if !ok {
+		return
+	}
+
+	cd, err := cmdDir(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get cmd dir err=%w", err))
+		return
+	}
+
+	c.info(fmt.Sprintf("looking for pull request of %s", cm.sha[:8]))
+	f := c.forge
+	go func() {
+		pr, err := findPullRequest(cd, f, cm)
+		if err != nil {
+			c.fail(fmt.Errorf("failed to find pull request of %s err=%w", cm.sha[:8], err))
+			return
+		}
+		if pr == nil {
+			c.warn(fmt.Sprintf("no pull request found for %s: %s", cm.sha[:8], tview.Escape(cm.summary)))
+			return
+		}
 
-	if c.githubBaseURL == "" {
+		c.info(fmt.Sprintf("[#4CAF50]%s[#000000]: pull request %s (via %s)", cm.sha[:8], pr.ref, pr.source))
+		c.open(pr.url)
+	}()
+}
+
+// committedLineCommit returns the commit of the current line and whether it
+// can be linked on the forge, warning the user if not.
+func (c *container) committedLineCommit() (*commit, bool) {
+	cm := c.lineCommit()
+	if cm == nil {
+		return nil, false
+	}
+	if c.forge == nil {
+		c.warn("no forge remote")
+		return nil, false
+	}
+	if cm.sha == uncommittedSHA {
+		c.warn("line is not committed yet")
+		return nil, false
+	}
+	return cm, true
+}
+
+func (c *container) openCommit() {
+	cm, ok := c.committedLineCommit()
+	if !ok {
 		return
 	}
+	c.open(c.forge.commitURL(cm.sha))
+}
 
-	prRef := extractPullRequestReference(cm.summary)
-	if prRef == "" {
+// openCompare opens the changes of the current line's commit compared to its
+// first parent.
+func (c *container) openCompare() {
+	cm, ok := c.committedLineCommit()
+	if !ok {
 		return
 	}
 
-	prURL, err := url.JoinPath(c.githubBaseURL, "pull", prRef)
+	cd, err := cmdDir(c.filePath)
 	if err != nil {
-		c.log = append(c.log, fmt.Sprintf("failed to construct pr url err=%v", err))
+		c.fail(fmt.Errorf("failed to get cmd dir err=%w", err))
 		return
 	}
 
-	openURL(prURL)
+	buf, err := runGit(cd, "rev-parse", "--verify", "--quiet", cm.sha+"^")
+	if err != nil {
+		c.warn(fmt.Sprintf("%s has no parent", cm.sha[:8]))
+		return
+	}
+	parent := strings.TrimSpace(string(buf))
+
+	c.open(c.forge.compareURL(parent, cm.sha))
+}
+
+// openPermalink opens the file at the blamed revision highlighting lines from
+// start to end (0-based).
+func (c *container) openPermalink(start, end int) {
+	link, err := c.permalink(start, end)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if link != "" {
+		c.open(link)
+	}
+}
+
+// permalink links to the file at the blamed revision highlighting lines from
+// start to end (0-based), it returns "" if there is no forge.
+func (c *container) permalink(start, end int) (string, error) {
+	if c.data == nil {
+		return "", nil
+	}
+	if c.lineCount == 0 {
+		c.warn("file has no lines")
+		return "", nil
+	}
+	if c.forge == nil {
+		c.warn("no forge remote")
+		return "", nil
+	}
+
+	cd, err := cmdDir(c.filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cmd dir err=%w", err)
+	}
+
+	rev := c.data.rev
+	if rev == "" {
+		rev = "HEAD"
+		if _, hasUncommitted := c.data.commits[uncommittedSHA]; hasUncommitted {
+			c.warn("file has uncommitted changes, lines may be off")
+		}
+	}
+	sha, err := resolveRev(cd, rev)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := repoPath(c.filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository path err=%w", err)
+	}
+
+	return c.forge.fileURL(sha, path, c.data.fileLine(start)+1, c.data.fileLine(end)+1), nil
+}
+
+// annotation describes lines start to end (0-based) as file:line by author
+// in sha.
+func (c *container) annotation(start, end int) string {
+	path, err := repoPath(c.filePath)
+	if err != nil {
+		path = c.filePath
+	}
+
+	lines := lineRange(c.data.fileLine(start), c.data.fileLine(end))
+
+	authors := []string{}
+	shas := []string{}
+	seen := map[string]bool{}
+	for i := start; i <= end; i++ {
+		cm := c.data.lineCommits[i]
+		if cm == nil || seen[cm.sha] {
+			continue
+		}
+		seen[cm.sha] = true
+		shas = append(shas, cm.sha[:8])
+		if !seen[cm.author.name] {
+			seen[cm.author.name] = true
+			authors = append(authors, cm.author.name)
+		}
+	}
+
+	return fmt.Sprintf("%s:%s by %s in %s", path, lines, strings.Join(authors, ", "), strings.Join(shas, ", "))
+}
+
+func (c *container) chooseCopy() {
+	cm := c.lineCommit()
+	if cm == nil {
+		return
+	}
+
+	type option struct {
+		key   rune
+		descr string
+		text  func() (string, error)
+	}
+	options := []option{
+		{'s', "full sha", func() (string, error) { return cm.sha, nil }},
+		{'S', "short sha", func() (string, error) { return cm.sha[:8], nil }},
+		{'m', "summary", func() (string, error) { return cm.summary, nil }},
+		{'p', "permalink", func() (string, error) { return c.permalink(c.selection()) }},
+		{'a', "annotation", func() (string, error) { return c.annotation(c.selection()), nil }},
+	}
+
+	list := newList(" copy ").ShowSecondaryText(false)
+	for _, o := range options {
+		list.AddItem(o.descr, "", o.key, nil)
+	}
+	list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		c.closeModal("copy")
+
+		text, err := options[i].text()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if text == "" {
+			return
+		}
+		c.copy(options[i].descr, text)
+	})
+	list.SetDoneFunc(func() { c.closeModal("copy") })
+
+	c.showModal("copy", list, 30, len(options)+2)
+}
+
+func (c *container) copy(descr, text string) {
+	cd, err := cmdDir(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get cmd dir err=%w", err))
+		return
+	}
+
+	err = copyToClipboard(cd, text)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to copy %s err=%w", descr, err))
+		return
+	}
+	messages.add(levelInfo, "copied %s %#v", descr, text)
+	c.info(fmt.Sprintf("copied %s", descr))
+}
+
+func (c *container) open(url string) {
+	messages.add(levelInfo, "opening %s", url)
+	err := openURL(url)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to open url err=%w", err))
+	}
 }
 
 func (c *container) showLogSummary() {
-	cm := c.data.lineCommits[c.currentLine]
-	c.info(fmt.Sprintf("[#4CAF50]%s[#000000]: %s", cm.sha[:8], cm.summary))
+	cm := c.lineCommit()
+	if cm == nil {
+		return
+	}
+	c.info(fmt.Sprintf("[#4CAF50]%s[#000000]: %s", cm.sha[:8], tview.Escape(cm.summary)))
 }
 
 func (c *container) previousFileRevision() {
-	youngestSha := c.data.sortedCommits[0].sha
-	nextRev := revBefore(c.revListDesc, youngestSha)
-	if nextRev == "" {
+	youngest := c.youngestCommit()
+	if youngest == nil {
+		c.warn("no committed revision")
+		return
+	}
+	nextRev, ok := revBefore(c.revListDesc, youngest.sha)
+	if !ok {
 		c.warn("reached oldest rev")
 		return
 	}
-	c.newRevision(nextRev)
+	c.navigateRevision(nextRev)
 }
 
 func (c *container) nextFileRevision() {
-	youngestSha := c.data.sortedCommits[0].sha
-	nextRev := revAfter(c.revListDesc, youngestSha)
-	if nextRev == "" {
+	youngest := c.youngestCommit()
+	if youngest == nil {
+		c.warn("no committed revision")
+		return
+	}
+	nextRev, ok := revAfter(c.revListDesc, youngest.sha)
+	if !ok {
 		c.warn("reached youngest rev")
 		return
 	}
-	c.newRevision(nextRev)
+	c.navigateRevision(nextRev)
 }
 
 func (c *container) afterLineRevision() {
-	lineCommit := c.data.lineCommits[c.currentLine]
-	nextRev := revAfter(c.revListDesc, lineCommit.sha)
-	if nextRev == "" {
+	lineCommit := c.lineCommit()
+	if lineCommit == nil {
+		return
+	}
+	nextRev, ok := revAfter(c.revListDesc, lineCommit.sha)
+	if !ok {
 		c.warn("reached youngest rev")
 		return
 	}
-	c.newRevision(nextRev)
+	c.navigateRevision(nextRev)
 }
 
 func (c *container) beforeLineRevision() {
-	lineCommit := c.data.lineCommits[c.currentLine]
-	nextRev := revBefore(c.revListDesc, lineCommit.sha)
-	if nextRev == "" {
+	lineCommit := c.lineCommit()
+	if lineCommit == nil {
+		return
+	}
+	nextRev, ok := revBefore(c.revListDesc, lineCommit.sha)
+	if !ok {
 		c.warn("reached oldest revision")
 		return
 	}
-	c.newRevision(nextRev)
+	c.navigateRevision(nextRev)
 }
 
-func revBefore(revList []string, rev string) string {
+func revBefore(revList []revision, sha string) (revision, bool) {
 	for i, r := range revList {
-		if r != rev {
+		if r.sha != sha {
 			continue
 		}
 
 		if i == len(revList)-1 {
-			return ""
+			return revision{}, false
 		}
-		return revList[i+1]
+		return revList[i+1], true
 	}
-	return ""
+	return revision{}, false
 }
 
-func revAfter(revList []string, rev string) string {
+func revAfter(revList []revision, sha string) (revision, bool) {
 	for i, r := range revList {
-		if r != rev {
+		if r.sha != sha {
 			continue
 		}
 
 		if i == 0 {
-			return ""
+			return revision{}, false
 		}
-		return revList[i-1]
+		return revList[i-1], true
 	}
-	return ""
+	return revision{}, false
+}
+
+// newRevision blames rev, "" for the working tree, and moves to line once
+// it's shown.
+func (c *container) newRevision(rev string, line int) {
+	c.blamePosition(position{filePath: c.filePath, rev: rev, line: line, opts: c.blameOptions()})
 }
 
-func (c *container) newRevision(rev string) {
+// blameOptions returns the options of the current blame.
+func (c *container) blameOptions() blameOptions {
+	if c.data == nil {
+		return blameOptions{}
+	}
+	return c.data.opts
+}
+
+// blamePosition blames pos in the active tab, switching the tab to pos's file
+// if it's another one.
+func (c *container) blamePosition(pos position) {
+	t := c.tab
+	current := t.filePath
+	currentRevList := t.revListDesc
 	go func() {
-		out, err := blame(c.filePath, rev)
+		var revList []revision
+		path, err := repoPath(pos.filePath)
 		if err != nil {
-			c.warn(err.Error())
+			c.fail(fmt.Errorf("failed to get repository path err=%w", err))
+			return
 		}
-		c.chBlame <- out
+		// renames of the file share its revision list.
+		if pos.filePath != current && !hasPath(currentRevList, path) {
+			revList, err = c.revList(pos.filePath)
+			if err != nil {
+				c.fail(fmt.Errorf("failed to get rev list of %s err=%w", pos.filePath, err))
+				return
+			}
+		}
+
+		out, err := blame(pos.filePath, pos.rev, pos.opts)
+		if err != nil {
+			c.fail(fmt.Errorf("failed to blame revision %s err=%w", pos.rev, err))
+			return
+		}
+		c.chBlame <- blameResult{tab: t, filePath: pos.filePath, revList: revList, data: out, line: pos.line}
 	}()
 }
 
 func (c *container) warn(msg string) {
-	go func() {
-		c.menubar.SetText(fmt.Sprintf("[#ff5544]%s[#000000]", msg))
-		c.app.Draw()
-		<-time.After(2 * time.Second)
-		c.menubar.SetText(c.menuContent())
-		c.app.Draw()
-	}()
+	messages.add(levelWarn, "%s", msg)
+	c.flash(fmt.Sprintf("[#ff5544]%s[#000000]", msg))
 }
 
 func (c *container) info(msg string) {
+	c.flash(fmt.Sprintf("[#000000]%s[#000000]", msg))
+}
+
+// flash shows text in the menubar for two seconds. Like fail, it's called
+// from goroutines as well as the event loop, so it updates via the latter.
+func (c *container) flash(text string) {
 	go func() {
-		c.menubar.SetText(fmt.Sprintf("[#000000]%s[#000000]", msg))
-		c.app.Draw()
+		c.app.QueueUpdateDraw(func() { c.menubar.SetText(text) })
 		<-time.After(2 * time.Second)
-		c.menubar.SetText(c.menuContent())
-		c.app.Draw()
+		c.app.QueueUpdateDraw(func() { c.menubar.SetText(c.menuContent()) })
 	}()
 }
 
-func (c *container) revList(filePath string) ([]string, error) {
+func (c *container) toggleMessages() {
+	c.showMessages = !c.showMessages
+	if !c.showMessages {
+		c.flexRoot.ResizeItem(c.messageView, 0, 0)
+		return
+	}
+
+	c.renderMessages()
+	c.flexRoot.ResizeItem(c.messageView, 10, 0)
+}
+
+func (c *container) renderMessages() {
+	var b strings.Builder
+	for i, e := range messages.all() {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(
+			&b,
+			"[#9e9e9e]%s [%s]%-5s[#000000] %s",
+			e.time.Format("15:04:05.000"),
+			e.level.color(),
+			e.level,
+			tview.Escape(e.msg),
+		)
+	}
+	c.messageView.SetText(b.String())
+	c.messageView.ScrollToEnd()
+}
+
+// revision is a revision of a file and the file's path relative to the
+// repository root at that revision.
+type revision struct {
+	sha  string
+	path string
+}
+
+// revList returns the revisions of HEAD's history that change filePath,
+// youngest first, following renames. Revisions that delete the file are left
+// out as there's nothing to blame.
+func (c *container) revList(filePath string) ([]revision, error) {
 	cd, err := cmdDir(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	args := []string{"rev-list", "HEAD", "--", filePath}
-	cmd := exec.Command("git", args...)
-	cmd.Dir = cd
-	buf, err := cmd.Output()
+	buf, err := runGit(cd, "log", "--follow", "--format=%H", "--name-status", "HEAD", "--", filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// merges have no status, their path is that of the younger revision.
+	path, err := repoPath(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	revList := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	revList := []revision{}
+	for _, line := range strings.Split(string(buf), "\n") {
+		fields := strings.Split(line, "\t")
+		switch {
+		case len(line) == 40 && len(fields) == 1:
+			if len(revList) > 0 {
+				path = revList[len(revList)-1].path
+			}
+			revList = append(revList, revision{sha: line, path: path})
+		case len(fields) < 2 || len(revList) == 0:
+		case fields[0] == "D":
+			revList = revList[:len(revList)-1]
+		default:
+			// renames and copies list the old path first.
+			revList[len(revList)-1].path = fields[len(fields)-1]
+		}
+	}
 	return revList, nil
 }
 
+// hasPath reports whether any of revList is at path.
+func hasPath(revList []revision, path string) bool {
+	for _, r := range revList {
+		if r.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// gitConfig returns the value of key in the repository at dir, "" if unset.
+func gitConfig(dir, key string) string {
+	buf, err := runGit(dir, "config", "--get", key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// runGit runs git with args in dir and returns its stdout. Errors include
+// git's stderr output when there is any.
+func runGit(dir string, args ...string) ([]byte, error) {
+	start := time.Now()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	buf, err := cmd.Output()
+
+	took := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		messages.add(levelGit, "git %s (%v, %v)", strings.Join(args, " "), took, err)
+	} else {
+		messages.add(levelGit, "git %s (%v)", strings.Join(args, " "), took)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return nil, fmt.Errorf("git %s: %s err=%w", args[0], strings.TrimSpace(string(exitErr.Stderr)), err)
+	}
+	return buf, err
+}
+
+// repoPath returns fp relative to the root of its repository, as used in
+// forge URLs.
+func repoPath(fp string) (string, error) {
+	cd, err := cmdDir(fp)
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := runGit(cd, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+
+	rel := fp
+	if filepath.IsAbs(fp) {
+		rel, err = filepath.Rel(cd, fp)
+		if err != nil {
+			return "", err
+		}
+	}
+	return path.Clean(strings.TrimSpace(string(buf)) + filepath.ToSlash(rel)), nil
+}
+
+// repoFile returns the path of the file at path relative to the root of
+// fp's repository, relative to the working directory unless fp is absolute.
+func repoFile(fp, path string) (string, error) {
+	root, err := repoRoot(fp)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, filepath.FromSlash(path)), nil
+}
+
+// repoRoot returns the root of fp's repository, relative to the working
+// directory unless fp is absolute.
+func repoRoot(fp string) (string, error) {
+	cd, err := cmdDir(fp)
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := runGit(cd, "rev-parse", "--show-cdup")
+	if err != nil {
+		return "", err
+	}
+	root := filepath.FromSlash(strings.TrimSpace(string(buf)))
+	if filepath.IsAbs(fp) {
+		root = filepath.Join(cd, root)
+	}
+	return filepath.Clean(root), nil
+}
+
+// cmdDir returns the directory to run git in for fp. Files that only exist in
+// history may be in directories that are gone, so it's the closest existing
+// one.
 func cmdDir(fp string) (string, error) {
 	if filepath.IsAbs(fp) {
-		return filepath.Dir(fp), nil
+		dir := filepath.Dir(fp)
+		for {
+			fi, err := os.Stat(dir)
+			if err == nil && fi.IsDir() {
+				return dir, nil
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return "", fmt.Errorf("no existing directory for %s", fp)
+			}
+			dir = parent
+		}
 	}
 
 	return os.Getwd()
 }
 
-func (c *container) setGithubBaseURL(filePath string) {
+func (c *container) setRemotes(filePath string) {
 	cd, err := cmdDir(filePath)
 	if err != nil {
-		c.log = append(c.log, fmt.Sprintf("failed to get cmd dir err=%v", err))
+		messages.add(levelWarn, "failed to get cmd dir err=%v", err)
 		return
 	}
 
-	args := []string{"remote", "-v"}
-	cmd := exec.Command("git", args...)
-	cmd.Dir = cd
-	buf, err := cmd.Output()
+	c.remotes, err = listRemotes(cd)
 	if err != nil {
-		c.log = append(c.log, fmt.Sprintf("failed to run git remote err=%v", err))
+		messages.add(levelWarn, "failed to list remotes err=%v", err)
 		return
 	}
 
-	rxSSH := regexp.MustCompile(`git@github.com:(.+)\.git`)
-	rxHTTP := regexp.MustCompile(`https://github.com/(.+)\.git`)
+	for _, r := range c.remotes {
+		if r.forge == nil {
+			messages.add(levelInfo, "unknown forge for remote %s %s", r.name, r.url)
+		}
+	}
 
-	for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
-		matches := rxSSH.FindAllStringSubmatch(line, -1)
-		if len(matches) == 1 && len(matches[0]) == 2 {
-			repo := matches[0][1]
-			c.githubBaseURL = fmt.Sprintf("https://github.com/%v", repo)
-			return
+	forgeRemotes := c.forgeRemotes()
+	if len(forgeRemotes) == 0 {
+		messages.add(levelInfo, "didn't find forge remote")
+		return
+	}
+	c.selectRemote(forgeRemotes[0])
+}
+
+// forgeRemotes returns the remotes with a known forge in order of preference.
+func (c *container) forgeRemotes() []remote {
+	res := []remote{}
+	for _, r := range c.remotes {
+		if r.forge != nil {
+			res = append(res, r)
 		}
+	}
+	return res
+}
 
-		matches = rxHTTP.FindAllStringSubmatch(line, -1)
-		if len(matches) == 1 && len(matches[0]) == 2 {
-			repo := matches[0][1]
-			c.githubBaseURL = fmt.Sprintf("https://github.com/%v", repo)
-			return
+func (c *container) selectRemote(r remote) {
+	c.remoteName = r.name
+	c.forge = r.forge
+	messages.add(levelInfo, "using remote %s %s (%s)", r.name, r.url, r.forge.name())
+}
+
+func (c *container) chooseRemote() {
+	remotes := c.forgeRemotes()
+	if len(remotes) < 2 {
+		c.warn("no other forge remote")
+		return
+	}
+
+	list := newList(" remote ")
+	for i, r := range remotes {
+		list.AddItem(fmt.Sprintf("%s (%s)", r.name, r.forge.name()), r.url, 0, nil)
+		if r.name == c.remoteName {
+			list.SetCurrentItem(i)
 		}
 	}
-	c.log = append(c.log, fmt.Sprintf("didn't find github base url"))
+	list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		c.selectRemote(remotes[i])
+		c.closeModal("remote")
+		c.info(fmt.Sprintf("using remote %s", remotes[i].name))
+	})
+	list.SetDoneFunc(func() { c.closeModal("remote") })
+
+	c.showModal("remote", list, 60, 2*len(remotes)+2)
+}
+
+func newList(title string) *tview.List {
+	list := tview.NewList().
+		SetHighlightFullLine(true).
+		SetMainTextColor(tcell.ColorBlack.TrueColor()).
+		SetSecondaryTextColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetShortcutColor(tcell.GetColor("#4CAF50").TrueColor()).
+		SetSelectedTextColor(tcell.ColorBlack.TrueColor()).
+		SetSelectedBackgroundColor(tcell.GetColor("#e8ecf0").TrueColor())
+	list.
+		SetBorder(true).
+		SetTitle(title).
+		SetTitleColor(tcell.ColorBlack.TrueColor()).
+		SetBorderColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	return list
+}
+
+// showModal shows p centered on top of the main view and focuses it.
+func (c *container) showModal(name string, p tview.Primitive, width, height int) {
+	centered := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+
+	c.pages.AddPage(name, centered, true, true)
+	c.app.SetFocus(p)
+}
+
+func (c *container) closeModal(name string) {
+	c.pages.RemovePage(name)
+	c.app.SetFocus(c.fileView)
+}
+
+// blameOptions change how blame attributes lines.
+type blameOptions struct {
+	// reverse blames from the revision to HEAD, attributing each line to the
+	// last revision it exists in rather than the one that introduced it.
+	reverse bool
+	// since and boundary limit attribution to commits more recent than the
+	// date since and that aren't ancestors of the revision boundary. Older
+	// lines are attributed to boundary commits. Neither applies to reverse
+	// blames.
+	since    string
+	boundary string
+	// function restricts the blame to the function of that name, the one
+	// starting closest to line funcStart (0-based) if there are several.
+	function  string
+	funcStart int
 }
 
-func blame(filePath string, upTo string) (*blameData, error) {
+func blame(filePath string, upTo string, opts blameOptions) (*blameData, error) {
 	cd, err := cmdDir(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	if upTo != "" {
-		cmd := exec.Command("git", "rev-parse", upTo)
-		cmd.Dir = cd
-		err := cmd.Run()
+		_, err := runGit(cd, "rev-parse", upTo)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	args := []string{"blame", "--porcelain", "-M", "-C"}
-	if upTo != "" {
+	// root commits aren't boundaries so that those only mark limited lines.
+	args := []string{"blame", "--porcelain", "-M", "-C", "--root"}
+	if !opts.reverse && opts.since != "" {
+		args = append(args, "--since="+opts.since)
+	}
+	if !opts.reverse && opts.boundary != "" {
+		args = append(args, "^"+opts.boundary)
+	}
+	if opts.function != "" {
+		// line numbers of reverse blames refer to upTo as well.
+		start, end, err := functionRange(filePath, upTo, opts.function, opts.funcStart)
+		if err != nil {
+			return nil, err
+		}
+		opts.funcStart = start
+		args = append(args, "-L", fmt.Sprintf("%d,%d", start+1, end+1))
+	}
+	switch {
+	case opts.reverse:
+		if upTo == "" {
+			return nil, fmt.Errorf("reverse blame requires a revision")
+		}
+		args = append(args, "--reverse", upTo+"..HEAD")
+	case upTo != "":
 		args = append(args, upTo)
 	}
 	args = append(args, "--", filePath)
-	cmd := exec.Command("git", args...)
-	cmd.Dir = cd
-	buf, err := cmd.Output()
+	buf, err := runGit(cd, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return parseBlameOutput(string(buf)), nil
+	res, err := parseBlameOutput(string(buf))
+	if err != nil {
+		return nil, err
+	}
+	res.rev = upTo
+	res.opts = opts
+	res.path, err = repoPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
 type author struct {
 	name  string
+	email string
 	color tcell.Color
+	// identities are the names and emails the author committed as.
+	identities []identity
 }
 
 type commit struct {
 	author     *author
 	authorTime time.Time
-	sha        string
-	color      tcell.Color
-	summary    string
+	// authorTZ and committerTZ are the time zones as recorded, e.g. +0200.
+	authorTZ      string
+	committer     identity
+	committerTime time.Time
+	committerTZ   string
+	sha           string
+	color         tcell.Color
+	summary       string
+	// boundary commits are outside the blamed range, lines attributed to
+	// them are older.
+	boundary bool
 }
 
 type blameData struct {
+	// rev is the blamed revision, "" for the working tree.
+	rev  string
+	opts blameOptions
+	// path is the blamed file relative to the root of its repository.
+	path          string
 	lines         []string
 	lineCommits   map[int]*commit
+	commits       map[string]*commit
 	sortedCommits []*commit
+	// origins holds where each line came from in its commit, which differs
+	// from path and the line's position for code that was moved or copied.
+	origins []origin
+	// firstLine is the line of the file (0-based) of lines[0], lines are a
+	// range of the file if blamed for a function.
+	firstLine int
+}
+
+// fileLine returns the line of the file (0-based) of lines[i].
+func (d *blameData) fileLine(i int) int {
+	return d.firstLine + i
+}
+
+// origin is a file path relative to the repository root and a 0-based line.
+type origin struct {
+	path string
+	line int
 }
 
-func parseBlameOutput(out string) *blameData {
+const uncommittedSHA = "0000000000000000000000000000000000000000"
+
+// boundaryColor is used for lines outside the blamed range.
+const boundaryColor = "#bdbdbd"
+
+func parseBlameOutput(out string) (*blameData, error) {
 	res := blameData{
 		lineCommits:   map[int]*commit{},
 		lines:         []string{},
+		commits:       map[string]*commit{},
 		sortedCommits: []*commit{},
 	}
 
-	commits := map[string]*commit{}
+	commits := res.commits
 	currentSHA := ""
+	currentLine := 0
+	// filename is only repeated for commits with lines from several files.
+	commitPaths := map[string]string{}
 
 	for _, rawLine := range strings.Split(string(out), "\n") {
+		if rawLine == "" {
+			continue
+		}
+
 		var isFileLine = strings.HasPrefix(rawLine, "\t")
 		if isFileLine {
 			trimmed := strings.TrimPrefix(rawLine, "\t")
 			res.lines = append(res.lines, trimmed)
 			res.lineCommits[len(res.lines)-1] = commits[currentSHA]
+			res.origins = append(res.origins, origin{path: commitPaths[currentSHA], line: currentLine})
 			continue
 		}
 
@@ -933,6 +1958,22 @@ func parseBlameOutput(out string) *blameData {
 		isStart := strings.Index(trimmed, " ") == 40
 		if isStart {
 			currentSHA = trimmed[:40]
+			fields := strings.Fields(trimmed)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid blame header %#v", trimmed)
+			}
+			num, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse original line of commit %s err=%w", currentSHA, err)
+			}
+			currentLine = num - 1
+			if len(res.lines) == 0 {
+				final, err := strconv.Atoi(fields[2])
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse line of commit %s err=%w", currentSHA, err)
+				}
+				res.firstLine = final - 1
+			}
 		}
 
 		meta, hasMeta := commits[currentSHA]
@@ -948,43 +1989,77 @@ func parseBlameOutput(out string) *blameData {
 				meta.author.name = "uncommitted"
 			}
 		}
+		if strings.HasPrefix(rawLine, "author-mail ") {
+			meta.author.email = strings.Trim(strings.TrimPrefix(rawLine, "author-mail "), "<>")
+		}
 		if strings.HasPrefix(rawLine, "author-time ") {
 			trimmed := strings.TrimPrefix(rawLine, "author-time ")
 			num, err := strconv.ParseInt(trimmed, 10, 64)
 			if err != nil {
-				fmt.Printf("failed to parse author time err=%v\n", err)
-				os.Exit(1)
+				return nil, fmt.Errorf("failed to parse author time of commit %s err=%w", currentSHA, err)
 			}
 			meta.authorTime = time.Unix(num, 0)
 		}
+		if strings.HasPrefix(rawLine, "author-tz ") {
+			meta.authorTZ = strings.TrimPrefix(rawLine, "author-tz ")
+		}
+		if strings.HasPrefix(rawLine, "committer ") {
+			meta.committer.name = strings.TrimPrefix(rawLine, "committer ")
+		}
+		if strings.HasPrefix(rawLine, "committer-mail ") {
+			meta.committer.email = strings.Trim(strings.TrimPrefix(rawLine, "committer-mail "), "<>")
+		}
+		if strings.HasPrefix(rawLine, "committer-time ") {
+			trimmed := strings.TrimPrefix(rawLine, "committer-time ")
+			num, err := strconv.ParseInt(trimmed, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse committer time of commit %s err=%w", currentSHA, err)
+			}
+			meta.committerTime = time.Unix(num, 0)
+		}
+		if strings.HasPrefix(rawLine, "committer-tz ") {
+			meta.committerTZ = strings.TrimPrefix(rawLine, "committer-tz ")
+		}
 		if strings.HasPrefix(rawLine, "summary ") {
 			trimmed := strings.TrimPrefix(rawLine, "summary ")
 			meta.summary = trimmed
 		}
+		if rawLine == "boundary" {
+			meta.boundary = true
+		}
+		if strings.HasPrefix(rawLine, "filename ") {
+			commitPaths[currentSHA] = strings.TrimPrefix(rawLine, "filename ")
+		}
 	}
 
-	authors := map[string]*author{}
+	for _, c := range commits {
+		if c.author == nil {
+			return nil, fmt.Errorf("missing author for commit %s", c.sha)
+		}
+	}
+	unifyAuthors(commits)
 
+	authors := []*author{}
+	seen := map[*author]bool{}
 	for _, c := range commits {
-		if c.sha == "0000000000000000000000000000000000000000" {
-			c.color = tcell.GetColor("#ee6002")
+		if c.sha == uncommittedSHA {
 			continue
 		}
-		c.author.color = tcell.GetColor(authorColor(c.author.name))
-		authors[c.author.name] = c.author
+		if !seen[c.author] {
+			seen[c.author] = true
+			authors = append(authors, c.author)
+		}
 		res.sortedCommits = append(res.sortedCommits, c)
 	}
+	assignAuthorColors(authors)
 	sort.SliceStable(res.sortedCommits, func(i, j int) bool {
 		ci, cj := res.sortedCommits[i], res.sortedCommits[j]
 		return ci.authorTime.After(cj.authorTime)
 	})
 
-	commitShades := generateShades("#00345d", "#4FC3F7", len(res.sortedCommits))
-	for i, c := range res.sortedCommits {
-		c.color = commitShades[i]
-	}
+	colorCommits(&res, colorByRank, time.Now())
 
-	return &res
+	return &res, nil
 }
 
 func hashString(s string) uint32 {
@@ -997,19 +2072,6 @@ func intToHex(n int) string {
 	return fmt.Sprintf("%02x", n)
 }
 
-func hashToColor(hash [20]byte) string {
-	r := hash[0]
-	g := hash[1]
-	b := hash[2]
-
-	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
-}
-
-func authorColor(author string) string {
-	hash := sha1.Sum([]byte(author))
-	return hashToColor(hash)
-}
-
 func hexToRGB(hex string) (int, int, int) {
 	var r, g, b int
 	fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b)
@@ -1033,7 +2095,10 @@ func generateShades(dark, light string, count int) []tcell.Color {
 
 	shades := make([]tcell.Color, count)
 	for i := 0; i < count; i++ {
-		factor := float64(i) / float64(count-1)
+		factor := 0.0
+		if count > 1 {
+			factor = float64(i) / float64(count-1)
+		}
 		r, g, b := interpolateColor(r1, g1, b1, r2, g2, b2, factor)
 		shades[i] = tcell.GetColor(rgbToHex(r, g, b))
 	}
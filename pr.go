@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pullRequest is the pull or merge request that introduced a commit.
+type pullRequest struct {
+	ref string
+	url string
+	// source describes how the pull request was found.
+	source string
+}
+
+var rxMergeMessages = []*regexp.Regexp{
+	regexp.MustCompile(`^Merge pull request #([0-9]+)`),
+	regexp.MustCompile(`(?m)^See merge request \S*!([0-9]+)`),
+	regexp.MustCompile(`\(pull request #([0-9]+)\)`),
+	regexp.MustCompile(`^Merge pull request '.*' \(#([0-9]+)\)`),
+	regexp.MustCompile(`^Merged PR ([0-9]+)`),
+}
+
+// mergeRequestRef extracts the pull request reference from the message of a
+// merge commit as created by GitHub, GitLab, Bitbucket, Gitea or Azure DevOps.
+func mergeRequestRef(msg string) string {
+	for _, rx := range rxMergeMessages {
+		matches := rx.FindStringSubmatch(msg)
+		if len(matches) == 2 {
+			return matches[1]
+		}
+	}
+	return ""
+}
+
+// findPullRequest locates the pull request that introduced cm. It looks for
+// the merge commit that brought cm into HEAD's first-parent history first,
+// then asks the forge's API if configured, and finally falls back to a
+// reference in the commit summary as left by squash merges. It returns nil if
+// no pull request was found.
+func findPullRequest(dir string, f forge, cm *commit) (*pullRequest, error) {
+	merge, err := introducingMerge(dir, cm.sha)
+	if err != nil {
+		return nil, err
+	}
+	if merge != "" {
+		buf, err := runGit(dir, "log", "-1", "--format=%B", merge)
+		if err != nil {
+			return nil, err
+		}
+		if ref := mergeRequestRef(string(buf)); ref != "" {
+			return &pullRequest{ref: ref, url: f.pullRequestURL(ref), source: "merge " + merge[:8]}, nil
+		}
+		messages.add(levelInfo, "merge %s doesn't reference a pull request", merge[:8])
+	}
+
+	if api, ok := f.(pullRequestAPI); ok {
+		if endpoint := apiEndpoint(dir, api); endpoint != "" {
+			pr, err := queryPullRequest(api, endpoint, apiToken(dir), cm.sha)
+			if err != nil {
+				messages.add(levelWarn, "failed to query forge api err=%v", err)
+			} else if pr != nil {
+				return pr, nil
+			}
+		}
+	}
+
+	if ref := f.pullRequestRef(cm.summary); ref != "" {
+		return &pullRequest{ref: ref, url: f.pullRequestURL(ref), source: "summary"}, nil
+	}
+	return nil, nil
+}
+
+// introducingMerge returns the oldest merge on HEAD's first-parent history
+// that has sha as an ancestor via a parent other than the first. It returns ""
+// if there is none, e.g. when sha was committed to that history directly.
+func introducingMerge(dir, sha string) (string, error) {
+	rng := sha + "..HEAD"
+	buf, err := runGit(dir, "rev-list", "--ancestry-path", "--merges", rng)
+	if err != nil {
+		return "", err
+	}
+	descendants := map[string]bool{}
+	for _, m := range strings.Fields(string(buf)) {
+		descendants[m] = true
+	}
+	if len(descendants) == 0 {
+		return "", nil
+	}
+
+	buf, err = runGit(dir, "rev-list", "--first-parent", "--merges", rng)
+	if err != nil {
+		return "", err
+	}
+	mainline := strings.Fields(string(buf))
+	for i := len(mainline) - 1; i >= 0; i-- {
+		m := mainline[i]
+		if !descendants[m] {
+			continue
+		}
+
+		_, err := runGit(dir, "merge-base", "--is-ancestor", sha, m+"^1")
+		if err == nil {
+			return "", nil
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return m, nil
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// pullRequestAPI is implemented by forges whose API can list the pull
+// requests that contain a commit.
+//
+// Querying the API is opt-in via git config gb.forgeApi, either "true" to use
+// the forge's default endpoint or the base URL of the API, e.g.
+// http://localhost:8080 to use a local stub. A token for private
+// repositories is read from gb.forgeToken or the GB_FORGE_TOKEN environment
+// variable.
+type pullRequestAPI interface {
+	forge
+	defaultAPIURL() string
+	commitPullRequestsURL(endpoint, sha string) string
+	authorize(req *http.Request, token string)
+	parsePullRequests(body []byte) (*pullRequest, error)
+}
+
+func apiEndpoint(dir string, api pullRequestAPI) string {
+	switch v := gitConfig(dir, "gb.forgeApi"); strings.ToLower(v) {
+	case "", "false":
+		return ""
+	case "true":
+		return api.defaultAPIURL()
+	default:
+		return strings.TrimSuffix(v, "/")
+	}
+}
+
+func apiToken(dir string) string {
+	if token := gitConfig(dir, "gb.forgeToken"); token != "" {
+		return token
+	}
+	return os.Getenv("GB_FORGE_TOKEN")
+}
+
+var apiClient = &http.Client{Timeout: 10 * time.Second}
+
+func queryPullRequest(api pullRequestAPI, endpoint, token, sha string) (*pullRequest, error) {
+	req, err := http.NewRequest(http.MethodGet, api.commitPullRequestsURL(endpoint, sha), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		api.authorize(req, token)
+	}
+
+	start := time.Now()
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	messages.add(levelInfo, "GET %s (%v, %s)", req.URL, time.Since(start).Round(time.Millisecond), resp.Status)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := api.parsePullRequests(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response err=%w", err)
+	}
+	if pr != nil {
+		pr.source = "api"
+	}
+	return pr, nil
+}
+
+// hostURL returns the scheme and host of the forge's web URL.
+func hostURL(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (f githubForge) defaultAPIURL() string {
+	if f.repo.host == "github.com" {
+		return "https://api.github.com"
+	}
+	return hostURL(f.baseURL) + "/api/v3"
+}
+
+func (f githubForge) commitPullRequestsURL(endpoint, sha string) string {
+	return joinURL(endpoint, "repos", f.repo.path, "commits", sha, "pulls")
+}
+
+func (f githubForge) authorize(req *http.Request, token string) {
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+func (f githubForge) parsePullRequests(body []byte) (*pullRequest, error) {
+	var prs []struct {
+		Number   int     `json:"number"`
+		HTMLURL  string  `json:"html_url"`
+		MergedAt *string `json:"merged_at"`
+	}
+	err := json.Unmarshal(body, &prs)
+	if err != nil || len(prs) == 0 {
+		return nil, err
+	}
+
+	pr := prs[0]
+	for _, p := range prs {
+		if p.MergedAt != nil {
+			pr = p
+			break
+		}
+	}
+	ref := fmt.Sprint(pr.Number)
+	if pr.HTMLURL == "" {
+		pr.HTMLURL = f.pullRequestURL(ref)
+	}
+	return &pullRequest{ref: ref, url: pr.HTMLURL}, nil
+}
+
+func (f gitlabForge) defaultAPIURL() string {
+	return hostURL(f.baseURL) + "/api/v4"
+}
+
+func (f gitlabForge) commitPullRequestsURL(endpoint, sha string) string {
+	return endpoint + "/projects/" + url.PathEscape(f.repo.path) + "/repository/commits/" + sha + "/merge_requests"
+}
+
+func (f gitlabForge) authorize(req *http.Request, token string) {
+	req.Header.Set("PRIVATE-TOKEN", token)
+}
+
+func (f gitlabForge) parsePullRequests(body []byte) (*pullRequest, error) {
+	var mrs []struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+		State  string `json:"state"`
+	}
+	err := json.Unmarshal(body, &mrs)
+	if err != nil || len(mrs) == 0 {
+		return nil, err
+	}
+
+	mr := mrs[0]
+	for _, m := range mrs {
+		if m.State == "merged" {
+			mr = m
+			break
+		}
+	}
+	ref := fmt.Sprint(mr.IID)
+	if mr.WebURL == "" {
+		mr.WebURL = f.pullRequestURL(ref)
+	}
+	return &pullRequest{ref: ref, url: mr.WebURL}, nil
+}
+
+func (f giteaForge) defaultAPIURL() string {
+	return hostURL(f.baseURL) + "/api/v1"
+}
+
+func (f giteaForge) commitPullRequestsURL(endpoint, sha string) string {
+	return joinURL(endpoint, "repos", f.repo.path, "commits", sha, "pull")
+}
+
+func (f giteaForge) authorize(req *http.Request, token string) {
+	req.Header.Set("Authorization", "token "+token)
+}
+
+func (f giteaForge) parsePullRequests(body []byte) (*pullRequest, error) {
+	var pr struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := json.Unmarshal(body, &pr)
+	if err != nil || pr.Number == 0 {
+		return nil, err
+	}
+
+	ref := fmt.Sprint(pr.Number)
+	if pr.HTMLURL == "" {
+		pr.HTMLURL = f.pullRequestURL(ref)
+	}
+	return &pullRequest{ref: ref, url: pr.HTMLURL}, nil
+}
+
+// defaultAPIURL is Bitbucket Cloud's API, Bitbucket Server's API differs and
+// isn't supported.
+func (f bitbucketForge) defaultAPIURL() string {
+	return "https://api.bitbucket.org/2.0"
+}
+
+func (f bitbucketForge) commitPullRequestsURL(endpoint, sha string) string {
+	return joinURL(endpoint, "repositories", f.repo.path, "commit", sha, "pullrequests")
+}
+
+func (f bitbucketForge) authorize(req *http.Request, token string) {
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+func (f bitbucketForge) parsePullRequests(body []byte) (*pullRequest, error) {
+	var page struct {
+		Values []struct {
+			ID    int `json:"id"`
+			Links struct {
+				HTML struct {
+					Href string `json:"href"`
+				} `json:"html"`
+			} `json:"links"`
+		} `json:"values"`
+	}
+	err := json.Unmarshal(body, &page)
+	if err != nil || len(page.Values) == 0 {
+		return nil, err
+	}
+
+	pr := page.Values[0]
+	ref := fmt.Sprint(pr.ID)
+	prURL := pr.Links.HTML.Href
+	if prURL == "" {
+		prURL = f.pullRequestURL(ref)
+	}
+	return &pullRequest{ref: ref, url: prURL}, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMergeRequestRef(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{msg: "Merge pull request #12 from fgeller/feature\n\nAdd feature", want: "12"},
+		{msg: "Merge branch 'feature' into 'main'\n\nAdd feature\n\nSee merge request fgeller/gb!34", want: "34"},
+		{msg: "Merge branch 'feature' into 'main'\n\nSee merge request !5", want: "5"},
+		{msg: "Merged in feature (pull request #56)\n\nAdd feature", want: "56"},
+		{msg: "Merge pull request 'Add feature' (#78) from feature into main", want: "78"},
+		{msg: "Merged PR 90: Add feature", want: "90"},
+		{msg: "Merge branch 'feature'", want: ""},
+		{msg: "Fix #12", want: ""},
+		{msg: "Add feature\n\nMerge pull request #12 from fgeller/feature", want: ""},
+		{msg: "Mention See merge request !5 inline", want: ""},
+	}
+	for _, tt := range tests {
+		got := mergeRequestRef(tt.msg)
+		if got != tt.want {
+			t.Errorf("mergeRequestRef(%#v) = %#v, want %#v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestQueryPullRequest(t *testing.T) {
+	const sha = "4a3f1c2b9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a"
+	tests := []struct {
+		name     string
+		api      pullRequestAPI
+		path     string
+		header   string
+		auth     string
+		response string
+		want     pullRequest
+	}{
+		{
+			name:     "github",
+			api:      githubForge{baseURL: "https://github.com/fgeller/gb", repo: remoteRepo{host: "github.com", path: "fgeller/gb"}},
+			path:     "/repos/fgeller/gb/commits/" + sha + "/pulls",
+			header:   "Authorization",
+			auth:     "Bearer secret",
+			response: `[{"number": 3, "html_url": "https://github.com/fgeller/gb/pull/3", "merged_at": null}, {"number": 4, "html_url": "https://github.com/fgeller/gb/pull/4", "merged_at": "2024-01-31T10:00:00Z"}]`,
+			want:     pullRequest{ref: "4", url: "https://github.com/fgeller/gb/pull/4", source: "api"},
+		},
+		{
+			name:     "gitlab",
+			api:      gitlabForge{baseURL: "https://gitlab.com/group/gb", repo: remoteRepo{host: "gitlab.com", path: "group/gb"}},
+			path:     "/projects/group%2Fgb/repository/commits/" + sha + "/merge_requests",
+			header:   "PRIVATE-TOKEN",
+			auth:     "secret",
+			response: `[{"iid": 5, "web_url": "", "state": "merged"}]`,
+			want:     pullRequest{ref: "5", url: "https://gitlab.com/group/gb/-/merge_requests/5", source: "api"},
+		},
+		{
+			name:     "gitea",
+			api:      giteaForge{baseURL: "https://codeberg.org/fgeller/gb", repo: remoteRepo{host: "codeberg.org", path: "fgeller/gb"}},
+			path:     "/repos/fgeller/gb/commits/" + sha + "/pull",
+			header:   "Authorization",
+			auth:     "token secret",
+			response: `{"number": 6, "html_url": "https://codeberg.org/fgeller/gb/pulls/6"}`,
+			want:     pullRequest{ref: "6", url: "https://codeberg.org/fgeller/gb/pulls/6", source: "api"},
+		},
+		{
+			name:     "bitbucket",
+			api:      bitbucketForge{baseURL: "https://bitbucket.org/fgeller/gb", repo: remoteRepo{host: "bitbucket.org", path: "fgeller/gb"}},
+			path:     "/repositories/fgeller/gb/commit/" + sha + "/pullrequests",
+			header:   "Authorization",
+			auth:     "Bearer secret",
+			response: `{"values": [{"id": 7, "links": {"html": {"href": "https://bitbucket.org/fgeller/gb/pull-requests/7"}}}]}`,
+			want:     pullRequest{ref: "7", url: "https://bitbucket.org/fgeller/gb/pull-requests/7", source: "api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != tt.path {
+					t.Errorf("path = %#v, want %#v", r.URL.EscapedPath(), tt.path)
+				}
+				if got := r.Header.Get(tt.header); got != tt.auth {
+					t.Errorf("%s header = %#v, want %#v", tt.header, got, tt.auth)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			pr, err := queryPullRequest(tt.api, srv.URL, "secret", sha)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pr == nil || *pr != tt.want {
+				t.Errorf("queryPullRequest = %+v, want %+v", pr, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryPullRequestStatus(t *testing.T) {
+	api := githubForge{baseURL: "https://github.com/fgeller/gb", repo: remoteRepo{host: "github.com", path: "fgeller/gb"}}
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "not found", status: http.StatusNotFound},
+		{name: "no pull requests", status: http.StatusOK, body: `[]`},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantErr: true},
+		{name: "invalid body", status: http.StatusOK, body: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			pr, err := queryPullRequest(api, srv.URL, "", "4a3f1c2b")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if pr != nil {
+				t.Errorf("queryPullRequest = %+v, want nil", pr)
+			}
+		})
+	}
+}
+
+// testRepo creates a repository with an initial commit with summary and
+// returns its directory and the commit.
+func testRepo(t *testing.T, summary string) (string, *commit) {
+	t.Helper()
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", summary)
+	return dir, &commit{sha: git(t, dir, "rev-parse", "HEAD"), summary: summary}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=gb", "-c", "user.email=gb@example.com"}, args...)...)
+	cmd.Dir = dir
+	buf, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed err=%v: %s", strings.Join(args, " "), err, buf)
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+func TestFindPullRequest(t *testing.T) {
+	repo := remoteRepo{host: "github.example.com", path: "fgeller/gb"}
+	forges := map[string]forge{
+		"github":    githubForge{baseURL: repo.webURL(), repo: repo},
+		"gitlab":    gitlabForge{baseURL: repo.webURL(), repo: repo},
+		"gitea":     giteaForge{baseURL: repo.webURL(), repo: repo},
+		"bitbucket": bitbucketForge{baseURL: repo.webURL(), repo: repo},
+	}
+	responses := map[string]string{
+		"github":    `[{"number": 12, "html_url": "https://example.com/12", "merged_at": "2024-01-31T10:00:00Z"}]`,
+		"gitlab":    `[{"iid": 12, "web_url": "https://example.com/12", "state": "merged"}]`,
+		"gitea":     `{"number": 12, "html_url": "https://example.com/12"}`,
+		"bitbucket": `{"values": [{"id": 12, "links": {"html": {"href": "https://example.com/12"}}}]}`,
+	}
+
+	for name, f := range forges {
+		t.Run(name, func(t *testing.T) {
+			summary := "Add feature (#9)"
+			if name == "gitlab" {
+				summary = "Add feature (!9)"
+			}
+			dir, cm := testRepo(t, summary)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.URL.Path, cm.sha) {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(responses[name]))
+			}))
+			defer srv.Close()
+
+			pr, err := findPullRequest(dir, f, cm)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pr == nil || pr.source != "summary" || pr.ref != "9" {
+				t.Errorf("without api findPullRequest = %+v, want #9 from summary", pr)
+			}
+
+			git(t, dir, "config", "gb.forgeApi", srv.URL)
+			pr, err = findPullRequest(dir, f, cm)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			want := pullRequest{ref: "12", url: "https://example.com/12", source: "api"}
+			if pr == nil || *pr != want {
+				t.Errorf("with api findPullRequest = %+v, want %+v", pr, want)
+			}
+		})
+	}
+}
+
+func TestFindPullRequestMerge(t *testing.T) {
+	dir, _ := testRepo(t, "Add feature")
+	git(t, dir, "checkout", "-q", "-b", "feature")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "Change feature")
+	sha := git(t, dir, "rev-parse", "HEAD")
+	git(t, dir, "checkout", "-q", "main")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "Change main")
+	git(t, dir, "merge", "-q", "--no-ff", "-m", "Merge pull request #7 from fgeller/feature", "feature")
+	merge := git(t, dir, "rev-parse", "HEAD")
+
+	repo := remoteRepo{host: "github.com", path: "fgeller/gb"}
+	f := githubForge{baseURL: repo.webURL(), repo: repo}
+	pr, err := findPullRequest(dir, f, &commit{sha: sha, summary: "Change feature"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := pullRequest{ref: "7", url: "https://github.com/fgeller/gb/pull/7", source: "merge " + merge[:8]}
+	if pr == nil || *pr != want {
+		t.Errorf("findPullRequest = %+v, want %+v", pr, want)
+	}
+
+	main := git(t, dir, "rev-parse", "HEAD^1")
+	pr, err = findPullRequest(dir, f, &commit{sha: main, summary: "Change main"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if pr != nil {
+		t.Errorf("findPullRequest of mainline commit = %+v, want nil", pr)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// remoteRepo is the location of a repository as parsed from a remote URL.
+type remoteRepo struct {
+	scheme string
+	host   string
+	port   string
+	// path is the repository path on host without leading slash and .git
+	// suffix, e.g. fgeller/gb.
+	path string
+}
+
+// webURL is the https URL of the repository on its host, http(s) remotes keep
+// their scheme and port as the web interface is likely served alongside.
+func (r remoteRepo) webURL() string {
+	host := r.host
+	scheme := "https"
+	if r.scheme == "http" || r.scheme == "https" {
+		scheme = r.scheme
+		if r.port != "" {
+			host += ":" + r.port
+		}
+	}
+	return scheme + "://" + host + "/" + r.path
+}
+
+// isSSH reports whether the repository is accessed via ssh, where host may be
+// an alias from the ssh config.
+func (r remoteRepo) isSSH() bool {
+	return r.scheme == "ssh"
+}
+
+// parseRemoteURL parses the URL forms that git accepts for remote
+// repositories:
+//
+//	ssh://[user@]host.xz[:port]/path/to/repo.git/
+//	git://host.xz[:port]/path/to/repo.git/
+//	http[s]://[user@]host.xz[:port]/path/to/repo.git/
+//	[user@]host.xz:path/to/repo.git/
+//	[user@host.xz:port]:path/to/repo.git/
+//
+// Local paths and file:// URLs are not remote and are rejected.
+func parseRemoteURL(raw string) (remoteRepo, bool) {
+	raw = strings.TrimSpace(raw)
+
+	var res remoteRepo
+	if scheme, _, ok := strings.Cut(raw, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "ssh", "git+ssh", "ssh+git":
+			res.scheme = "ssh"
+		case "git", "http", "https", "ftp", "ftps":
+			res.scheme = strings.ToLower(scheme)
+		default:
+			return res, false
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			return res, false
+		}
+		res.host = u.Hostname()
+		res.port = u.Port()
+		res.path = u.Path
+	} else {
+		// the scp-like syntax is only recognized if there are no slashes
+		// before the first colon, otherwise it's a local path.
+		var hostPart string
+		if strings.HasPrefix(raw, "[") && strings.Contains(raw, "]:") {
+			hostPart, res.path, _ = strings.Cut(raw[1:], "]:")
+		} else {
+			colon := strings.Index(raw, ":")
+			slash := strings.Index(raw, "/")
+			if colon <= 0 || (slash >= 0 && slash < colon) {
+				return res, false
+			}
+			hostPart, res.path = raw[:colon], raw[colon+1:]
+		}
+
+		if i := strings.LastIndex(hostPart, "@"); i >= 0 {
+			hostPart = hostPart[i+1:]
+		}
+		if h, p, ok := strings.Cut(hostPart, ":"); ok && !strings.Contains(p, ":") {
+			hostPart, res.port = h, p
+		}
+		res.scheme = "ssh"
+		res.host = strings.Trim(hostPart, "[]")
+	}
+
+	res.host = strings.ToLower(res.host)
+	res.path = strings.Trim(res.path, "/")
+	res.path = strings.TrimSuffix(res.path, ".git")
+	res.path = strings.TrimPrefix(res.path, "~/")
+	if res.host == "" || res.path == "" {
+		return res, false
+	}
+	return res, true
+}
+
+// remote is a configured git remote.
+type remote struct {
+	name  string
+	url   string
+	repo  remoteRepo
+	forge forge
+}
+
+// defaultRemotePreference is used if gb.remotePreference is not configured.
+var defaultRemotePreference = []string{"upstream", "origin"}
+
+// listRemotes returns the fetch remotes of the repository at dir, ordered by
+// preference: gb.remote if configured, then the remotes named in
+// gb.remotePreference (default "upstream origin"), then all others in the
+// order of git remote -v. URLs are reported by git with url.<base>.insteadOf
+// rewrites applied, ssh host aliases are resolved via ssh -G.
+func listRemotes(dir string) ([]remote, error) {
+	buf, err := runGit(dir, "remote", "-v")
+	if err != nil {
+		return nil, err
+	}
+
+	configured := configuredForges(dir)
+	res := []remote{}
+	for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[2] != "(fetch)" {
+			continue
+		}
+
+		r := remote{name: fields[0], url: fields[1]}
+		repo, ok := parseRemoteURL(r.url)
+		if ok {
+			if repo.isSSH() && forgeKind(repo.host, configured) == "" {
+				repo.host = resolveSSHHost(repo.host)
+			}
+			r.repo = repo
+			r.forge = newForge(repo, configured)
+		}
+		res = append(res, r)
+	}
+
+	preference := defaultRemotePreference
+	if pref := gitConfig(dir, "gb.remotePreference"); pref != "" {
+		preference = strings.FieldsFunc(pref, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	if name := gitConfig(dir, "gb.remote"); name != "" {
+		preference = append([]string{name}, preference...)
+	}
+
+	rank := func(r remote) int {
+		for i, name := range preference {
+			if name == r.name {
+				return i
+			}
+		}
+		return len(preference)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return rank(res[i]) < rank(res[j]) })
+
+	return res, nil
+}
+
+// resolveSSHHost returns the hostname that ssh connects to for host, which
+// may be a Host alias in the user's ssh config. The host comes from the
+// repository's config, so it's never passed to ssh as an option.
+func resolveSSHHost(host string) string {
+	if strings.HasPrefix(host, "-") {
+		return host
+	}
+	buf, err := exec.Command("ssh", "-G", "--", host).Output()
+	if err != nil {
+		return host
+	}
+
+	for _, line := range strings.Split(string(buf), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if ok && key == "hostname" && value != "" {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return host
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// fileBlame is the blame of one of several files.
+type fileBlame struct {
+	path string
+	data *blameData
+}
+
+// listFiles returns the files under paths that are tracked, or that exist at
+// rev if it isn't "", relative to the working directory.
+func listFiles(paths []string, rev string) ([]string, error) {
+	cd, err := cmdDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"ls-files", "-z"}
+	if rev != "" {
+		args = []string{"ls-tree", "-r", "-z", "--name-only", rev}
+	}
+	args = append(append(args, "--"), paths...)
+	buf, err := runGit(cd, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for _, f := range strings.Split(string(buf), "\x00") {
+		if f != "" {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+// blameFiles blames files at rev with a pool of workers, keeping the order of
+// files. Files that fail to blame, e.g. submodules, are left out and their
+// errors returned.
+func blameFiles(files []string, rev string, workers int) ([]fileBlame, []error) {
+	results := make([]*blameData, len(files))
+	errs := make([]error, len(files))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(1, workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = blame(files[i], rev, blameOptions{})
+				if errs[i] != nil {
+					errs[i] = fmt.Errorf("failed to blame %s err=%w", files[i], errs[i])
+				}
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	res := []fileBlame{}
+	failed := []error{}
+	for i, f := range files {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		res = append(res, fileBlame{path: f, data: results[i]})
+	}
+	return res, failed
+}
+
+// ownership is the number of lines per author of a file, a directory or all
+// reported files.
+type ownership struct {
+	path    string
+	lines   int
+	authors map[identity]int
+}
+
+func (o *ownership) add(id identity, lines int) {
+	o.lines += lines
+	o.authors[id] += lines
+}
+
+type authorLines struct {
+	identity
+	lines int
+}
+
+// sortedAuthors returns the authors of o, most lines first.
+func (o *ownership) sortedAuthors() []authorLines {
+	res := []authorLines{}
+	for id, n := range o.authors {
+		res = append(res, authorLines{identity: id, lines: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].lines != res[j].lines {
+			return res[i].lines > res[j].lines
+		}
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+// ownershipReport is the ownership of all reported files, of each directory
+// under the reported paths and of each file.
+type ownershipReport struct {
+	total       *ownership
+	directories []*ownership
+	files       []*ownership
+}
+
+// newOwnershipReport aggregates the lines per author of blames. Authors are
+// unified across files as within a file, see unifyAuthors. Uncommitted lines
+// are left out.
+func newOwnershipReport(blames []fileBlame, roots []string) *ownershipReport {
+	weights := map[identity]int{}
+	links := [][]identity{}
+	seen := map[*author]bool{}
+	for _, b := range blames {
+		for _, cm := range b.data.lineCommits {
+			if cm.sha == uncommittedSHA {
+				continue
+			}
+			weights[identity{name: cm.author.name, email: cm.author.email}] += 1
+			if !seen[cm.author] {
+				seen[cm.author] = true
+				links = append(links, cm.author.identities)
+			}
+		}
+	}
+	canonical := canonicalIdentities(weights, links)
+
+	res := &ownershipReport{total: &ownership{authors: map[identity]int{}}}
+	dirs := map[string]*ownership{}
+	for _, b := range blames {
+		file := &ownership{path: b.path, authors: map[identity]int{}}
+		for _, cm := range b.data.lineCommits {
+			if cm.sha == uncommittedSHA {
+				continue
+			}
+			file.add(canonical[identity{name: cm.author.name, email: cm.author.email}], 1)
+		}
+		res.files = append(res.files, file)
+
+		for id, n := range file.authors {
+			res.total.add(id, n)
+		}
+		for dir := filepath.Dir(b.path); within(dir, roots); dir = filepath.Dir(dir) {
+			d, ok := dirs[dir]
+			if !ok {
+				d = &ownership{path: dir, authors: map[identity]int{}}
+				dirs[dir] = d
+			}
+			for id, n := range file.authors {
+				d.add(id, n)
+			}
+			if dir == "." || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	for _, d := range dirs {
+		res.directories = append(res.directories, d)
+	}
+	sort.Slice(res.directories, func(i, j int) bool { return res.directories[i].path < res.directories[j].path })
+	return res
+}
+
+// within reports whether dir is one of roots or in one of them.
+func within(dir string, roots []string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+	for _, r := range roots {
+		r = filepath.Clean(r)
+		if r == "." || dir == r || strings.HasPrefix(dir, r+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ownershipReport) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "AUTHOR\tEMAIL\tLINES\t%")
+	for _, a := range r.total.sortedAuthors() {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", a.name, a.email, a.lines, percent(a.lines, r.total.lines))
+	}
+
+	for _, section := range []struct {
+		title string
+		rows  []*ownership
+	}{{"DIRECTORY", r.directories}, {"FILE", r.files}} {
+		fmt.Fprintf(tw, "\n%s\tAUTHOR\tLINES\t%%\n", section.title)
+		for _, o := range section.rows {
+			for i, a := range o.sortedAuthors() {
+				path := o.path
+				if i > 0 {
+					path = ""
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", path, a.name, a.lines, percent(a.lines, o.lines))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+type jsonAuthorLines struct {
+	Name    string  `json:"name"`
+	Email   string  `json:"email"`
+	Lines   int     `json:"lines"`
+	Percent float64 `json:"percent"`
+}
+
+type jsonOwnership struct {
+	Path    string            `json:"path,omitempty"`
+	Lines   int               `json:"lines"`
+	Authors []jsonAuthorLines `json:"authors"`
+}
+
+func (o *ownership) json() jsonOwnership {
+	res := jsonOwnership{Path: o.path, Lines: o.lines, Authors: []jsonAuthorLines{}}
+	for _, a := range o.sortedAuthors() {
+		res.Authors = append(res.Authors, jsonAuthorLines{
+			Name:    a.name,
+			Email:   a.email,
+			Lines:   a.lines,
+			Percent: 100 * float64(a.lines) / float64(o.lines),
+		})
+	}
+	return res
+}
+
+func (r *ownershipReport) writeJSON(w io.Writer) error {
+	out := struct {
+		Total       jsonOwnership   `json:"total"`
+		Directories []jsonOwnership `json:"directories"`
+		Files       []jsonOwnership `json:"files"`
+	}{Total: r.total.json(), Directories: []jsonOwnership{}, Files: []jsonOwnership{}}
+	for _, d := range r.directories {
+		out.Directories = append(out.Directories, d.json())
+	}
+	for _, f := range r.files {
+		out.Files = append(out.Files, f.json())
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func (r *ownershipReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"scope", "path", "author", "email", "lines", "percent"})
+	write := func(scope string, o *ownership) {
+		for _, a := range o.sortedAuthors() {
+			pct := fmt.Sprintf("%.1f", 100*float64(a.lines)/float64(o.lines))
+			cw.Write([]string{scope, o.path, a.name, a.email, fmt.Sprint(a.lines), pct})
+		}
+	}
+	write("total", r.total)
+	for _, d := range r.directories {
+		write("directory", d)
+	}
+	for _, f := range r.files {
+		write("file", f)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// runReport implements the report subcommand, which writes the lines per
+// author of the tracked files under the given paths to out.
+func runReport(args []string, out, errOut io.Writer) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	rev := fs.String("rev", "", "blame this revision instead of the working tree")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of files to blame in parallel")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "usage: gb report [flags] [path...]")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var write func(*ownershipReport, io.Writer) error
+	switch *format {
+	case "table":
+		write = (*ownershipReport).writeTable
+	case "json":
+		write = (*ownershipReport).writeJSON
+	case "csv":
+		write = (*ownershipReport).writeCSV
+	default:
+		return fmt.Errorf("unknown format %#v", *format)
+	}
+
+	paths := fs.Args()
+	files, err := listFiles(paths, *rev)
+	if err != nil {
+		return fmt.Errorf("failed to list files err=%w", err)
+	}
+	if len(files) == 0 && *rev != "" {
+		return fmt.Errorf("no files under %s at %s", strings.Join(paths, ", "), *rev)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no tracked files under %s", strings.Join(paths, ", "))
+	}
+
+	blames, errs := blameFiles(files, *rev, *workers)
+	for _, err := range errs {
+		fmt.Fprintln(errOut, err)
+	}
+
+	return write(newOwnershipReport(blames, paths), out)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// blameLine is a line of a porcelain blame fixture.
+type blameLine struct {
+	sha, name, email, text string
+}
+
+// porcelain returns the git blame --porcelain output of lines, commit headers
+// are only written with a commit's first line like git does.
+func porcelain(lines ...blameLine) string {
+	b := &strings.Builder{}
+	seen := map[string]bool{}
+	for i, l := range lines {
+		fmt.Fprintf(b, "%s %d %d\n", l.sha, i+1, i+1)
+		if !seen[l.sha] {
+			seen[l.sha] = true
+			fmt.Fprintf(b, "author %s\nauthor-mail <%s>\nauthor-time 1700000000\nauthor-tz +0000\n", l.name, l.email)
+			fmt.Fprintf(b, "committer %s\ncommitter-mail <%s>\ncommitter-time 1700000000\ncommitter-tz +0000\n", l.name, l.email)
+			fmt.Fprintf(b, "summary Change %s\nfilename file\n", l.sha[:8])
+		}
+		fmt.Fprintf(b, "\t%s\n", l.text)
+	}
+	return b.String()
+}
+
+func fixtureBlame(t *testing.T, path string, lines ...blameLine) fileBlame {
+	t.Helper()
+	data, err := parseBlameOutput(porcelain(lines...))
+	if err != nil {
+		t.Fatalf("failed to parse fixture of %s err=%v", path, err)
+	}
+	return fileBlame{path: path, data: data}
+}
+
+func reportFixture(t *testing.T) []fileBlame {
+	alice := func(text string) blameLine {
+		return blameLine{sha: strings.Repeat("a", 40), name: "Alice", email: "alice@example.com", text: text}
+	}
+	bob := func(text string) blameLine {
+		return blameLine{sha: strings.Repeat("b", 40), name: "Bob", email: "bob@example.com", text: text}
+	}
+	return []fileBlame{
+		fixtureBlame(t, "cmd/gb/main.go", alice("package main"), alice(""), bob("func main() {}")),
+		fixtureBlame(t, "cmd/tool.go", bob("package cmd")),
+		fixtureBlame(t, "README",
+			blameLine{sha: strings.Repeat("c", 40), name: "A. Liddell", email: "Alice@Example.com", text: "# gb"},
+			blameLine{sha: uncommittedSHA, name: "Not Committed Yet", email: "not.committed.yet", text: "draft"},
+		),
+	}
+}
+
+func TestNewOwnershipReport(t *testing.T) {
+	alice := identity{name: "Alice", email: "alice@example.com"}
+	bob := identity{name: "Bob", email: "bob@example.com"}
+	blames := reportFixture(t)
+
+	tests := []struct {
+		name   string
+		blames []fileBlame
+		roots  []string
+		total  map[identity]int
+		dirs   map[string]map[identity]int
+		files  map[string]map[identity]int
+	}{
+		{
+			name:   "all",
+			blames: blames,
+			total:  map[identity]int{alice: 3, bob: 2},
+			dirs: map[string]map[identity]int{
+				".":      {alice: 3, bob: 2},
+				"cmd":    {alice: 2, bob: 2},
+				"cmd/gb": {alice: 2, bob: 1},
+			},
+			files: map[string]map[identity]int{
+				"cmd/gb/main.go": {alice: 2, bob: 1},
+				"cmd/tool.go":    {bob: 1},
+				"README":         {alice: 1},
+			},
+		},
+		{
+			name:   "under root",
+			blames: blames[:2],
+			roots:  []string{"cmd/"},
+			total:  map[identity]int{alice: 2, bob: 2},
+			dirs: map[string]map[identity]int{
+				"cmd":    {alice: 2, bob: 2},
+				"cmd/gb": {alice: 2, bob: 1},
+			},
+			files: map[string]map[identity]int{
+				"cmd/gb/main.go": {alice: 2, bob: 1},
+				"cmd/tool.go":    {bob: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newOwnershipReport(tt.blames, tt.roots)
+			check := func(what string, o *ownership, want map[identity]int) {
+				t.Helper()
+				lines := 0
+				for _, n := range want {
+					lines += n
+				}
+				if o.lines != lines || fmt.Sprint(o.authors) != fmt.Sprint(want) {
+					t.Errorf("%s = %d %v, want %d %v", what, o.lines, o.authors, lines, want)
+				}
+			}
+			check("total", r.total, tt.total)
+
+			if len(r.directories) != len(tt.dirs) {
+				t.Errorf("directories = %d, want %d", len(r.directories), len(tt.dirs))
+			}
+			for i, d := range r.directories {
+				if i > 0 && r.directories[i-1].path >= d.path {
+					t.Errorf("directories aren't sorted, %s before %s", r.directories[i-1].path, d.path)
+				}
+				check("directory "+d.path, d, tt.dirs[d.path])
+			}
+
+			if len(r.files) != len(tt.files) {
+				t.Errorf("files = %d, want %d", len(r.files), len(tt.files))
+			}
+			for _, f := range r.files {
+				check("file "+f.path, f, tt.files[f.path])
+			}
+		})
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		dir   string
+		roots []string
+		want  bool
+	}{
+		{dir: "cmd", want: true},
+		{dir: ".", roots: []string{"."}, want: true},
+		{dir: "cmd/gb", roots: []string{"./"}, want: true},
+		{dir: "cmd", roots: []string{"cmd"}, want: true},
+		{dir: "cmd/gb", roots: []string{"cmd/"}, want: true},
+		{dir: "cmd/gb", roots: []string{"docs", "cmd"}, want: true},
+		{dir: "cmdline", roots: []string{"cmd"}, want: false},
+		{dir: "cmd", roots: []string{"cmd/gb"}, want: false},
+		{dir: ".", roots: []string{"cmd"}, want: false},
+	}
+	for _, tt := range tests {
+		got := within(tt.dir, tt.roots)
+		if got != tt.want {
+			t.Errorf("within(%#v, %#v) = %v, want %v", tt.dir, tt.roots, got, tt.want)
+		}
+	}
+}
+
+func TestOwnershipReportWriters(t *testing.T) {
+	r := newOwnershipReport(reportFixture(t), nil)
+
+	table := &strings.Builder{}
+	if err := r.writeTable(table); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wantTable := `AUTHOR  EMAIL              LINES  %
+Alice   alice@example.com  3      60%
+Bob     bob@example.com    2      40%
+
+DIRECTORY  AUTHOR  LINES  %
+.          Alice   3      60%
+           Bob     2      40%
+cmd        Alice   2      50%
+           Bob     2      50%
+cmd/gb     Alice   2      67%
+           Bob     1      33%
+
+FILE            AUTHOR  LINES  %
+cmd/gb/main.go  Alice   2      67%
+                Bob     1      33%
+cmd/tool.go     Bob     1      100%
+README          Alice   1      100%
+`
+	if table.String() != wantTable {
+		t.Errorf("writeTable =\n%s\nwant\n%s", table, wantTable)
+	}
+
+	csv := &strings.Builder{}
+	if err := r.writeCSV(csv); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wantCSV := `scope,path,author,email,lines,percent
+total,,Alice,alice@example.com,3,60.0
+total,,Bob,bob@example.com,2,40.0
+directory,.,Alice,alice@example.com,3,60.0
+directory,.,Bob,bob@example.com,2,40.0
+directory,cmd,Alice,alice@example.com,2,50.0
+directory,cmd,Bob,bob@example.com,2,50.0
+directory,cmd/gb,Alice,alice@example.com,2,66.7
+directory,cmd/gb,Bob,bob@example.com,1,33.3
+file,cmd/gb/main.go,Alice,alice@example.com,2,66.7
+file,cmd/gb/main.go,Bob,bob@example.com,1,33.3
+file,cmd/tool.go,Bob,bob@example.com,1,100.0
+file,README,Alice,alice@example.com,1,100.0
+`
+	if csv.String() != wantCSV {
+		t.Errorf("writeCSV =\n%s\nwant\n%s", csv, wantCSV)
+	}
+
+	buf := &strings.Builder{}
+	if err := r.writeJSON(buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var got struct {
+		Total       jsonOwnership   `json:"total"`
+		Directories []jsonOwnership `json:"directories"`
+		Files       []jsonOwnership `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("invalid json err=%v: %s", err, buf)
+	}
+	wantTotal := jsonOwnership{Lines: 5, Authors: []jsonAuthorLines{
+		{Name: "Alice", Email: "alice@example.com", Lines: 3, Percent: 60},
+		{Name: "Bob", Email: "bob@example.com", Lines: 2, Percent: 40},
+	}}
+	if fmt.Sprint(got.Total) != fmt.Sprint(wantTotal) {
+		t.Errorf("json total = %+v, want %+v", got.Total, wantTotal)
+	}
+	if len(got.Directories) != 3 || got.Directories[1].Path != "cmd" || got.Directories[1].Authors[0].Percent != 50 {
+		t.Errorf("json directories = %+v, want ., cmd and cmd/gb with cmd split evenly", got.Directories)
+	}
+	if len(got.Files) != 3 || got.Files[2].Path != "README" || got.Files[2].Lines != 1 {
+		t.Errorf("json files = %+v, want README with one committed line last", got.Files)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// toggleReverse switches between blaming the current revision and blaming it
+// in reverse up to HEAD, which shows for each line the last revision it
+// exists in.
+func (c *container) toggleReverse() {
+	opts := c.data.opts
+	if opts.reverse {
+		opts.reverse = false
+		c.navigateTo(position{filePath: c.filePath, rev: c.data.rev, line: c.currentLine, opts: opts})
+		return
+	}
+
+	cd, err := cmdDir(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get cmd dir err=%w", err))
+		return
+	}
+
+	rev := c.data.rev
+	head, err := resolveRev(cd, "HEAD")
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if rev != "" {
+		rev, err = resolveRev(cd, rev)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+	}
+	if rev == "" || rev == head {
+		c.warn("reverse blame needs a revision before HEAD, step back with < or b first")
+		return
+	}
+
+	opts.reverse = true
+	c.navigateTo(position{filePath: c.filePath, rev: rev, line: c.currentLine, opts: opts})
+}
+
+// gotoRemoval navigates to the revision that removed the current line of a
+// reverse blame, which is the first revision of the file after the last one
+// the line exists in.
+func (c *container) gotoRemoval() {
+	if !c.data.opts.reverse {
+		c.warn("not a reverse blame, toggle with r")
+		return
+	}
+	cm := c.lineCommit()
+	if cm == nil {
+		return
+	}
+
+	cd, err := cmdDir(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get cmd dir err=%w", err))
+		return
+	}
+
+	head, err := resolveRev(cd, "HEAD")
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if cm.sha == head {
+		c.warn("line still exists at HEAD")
+		return
+	}
+
+	// the line's file may have been renamed since the blamed revision.
+	o := c.data.origins[c.currentLine]
+	fp, err := repoFile(c.filePath, o.path)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get repository root err=%w", err))
+		return
+	}
+
+	buf, err := runGit(cd, "rev-list", "--ancestry-path", "--reverse", cm.sha+"..HEAD", "--", fp)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to list revisions after %s err=%w", cm.sha[:8], err))
+		return
+	}
+	revs := strings.Fields(string(buf))
+	if len(revs) == 0 {
+		c.warn(fmt.Sprintf("no revision of %s after %s", o.path, cm.sha[:8]))
+		return
+	}
+	removal := revs[0]
+
+	buf, err = runGit(cd, "log", "-1", "--format=%s", removal)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get summary of %s err=%w", removal[:8], err))
+		return
+	}
+	summary := tview.Escape(strings.TrimSpace(string(buf)))
+
+	if checkExists(fp, removal) != nil {
+		c.info(fmt.Sprintf("[#4CAF50]%s[#000000]: removed with the file: %s", removal[:8], summary))
+		return
+	}
+
+	c.info(fmt.Sprintf("[#4CAF50]%s[#000000]: removed in %s", removal[:8], summary))
+	opts := c.data.opts
+	opts.reverse = false
+	c.navigateTo(position{filePath: fp, rev: removal, line: o.line, opts: opts})
+}
+
+// resolveRev returns the commit sha of rev.
+func resolveRev(dir, rev string) (string, error) {
+	buf, err := runGit(dir, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve revision %s err=%w", rev, err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// shortRev abbreviates rev if it's a sha.
+func shortRev(rev string) string {
+	if len(rev) == 40 && strings.Trim(rev, "0123456789abcdef") == "" {
+		return rev[:8]
+	}
+	return rev
+}
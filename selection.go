@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// selectedColor is the background of selected lines.
+const selectedColor = "#cfe3f7"
+
+// selection returns the first and last selected line (0-based), the current
+// line if there's no selection.
+func (c *container) selection() (int, int) {
+	if !c.selecting {
+		return c.currentLine, c.currentLine
+	}
+	return min(c.anchor, c.currentLine), max(c.anchor, c.currentLine)
+}
+
+// isSelected reports whether line i is part of the selection.
+func (c *container) isSelected(i int) bool {
+	start, end := c.selection()
+	return c.selecting && i >= start && i <= end
+}
+
+// toggleSelection starts selecting lines from the current line or ends the
+// selection.
+func (c *container) toggleSelection() {
+	c.selecting = !c.selecting
+	c.anchor = c.currentLine
+	c.menubar.SetText(c.menuContent())
+	c.render()
+}
+
+// extendSelection starts a selection at the current line unless there is one
+// and moves the current line by delta.
+func (c *container) extendSelection(delta int) {
+	if !c.selecting {
+		c.selecting = true
+		c.anchor = c.currentLine
+	}
+	if delta > 0 {
+		c.scrollDown()
+	} else {
+		c.scrollUp()
+	}
+	c.menubar.SetText(c.menuContent())
+}
+
+func (c *container) clearSelection() {
+	c.selecting = false
+	c.menubar.SetText(c.menuContent())
+	c.render()
+}
+
+// lineRange formats lines start to end (0-based) as 1-based n or n-m.
+func lineRange(start, end int) string {
+	if start == end {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d-%d", start+1, end+1)
+}
+
+// slice returns the view of lines start to end (0-based) of d, sharing its
+// commits.
+func (d *blameData) slice(start, end int) *blameData {
+	res := *d
+	res.lines = d.lines[start : end+1]
+	res.origins = d.origins[start : end+1]
+	res.firstLine = d.fileLine(start)
+	res.lineCommits = map[int]*commit{}
+	for i := start; i <= end; i++ {
+		res.lineCommits[i-start] = d.lineCommits[i]
+	}
+	return &res
+}
+
+// showSelectionSummary shows the authors and commits of the selected lines.
+func (c *container) showSelectionSummary() {
+	if c.lineCount == 0 {
+		c.warn("file has no lines")
+		return
+	}
+	start, end := c.selection()
+	data := c.data.slice(start, end)
+	total := len(data.lines)
+	grey, black, green := tcell.GetColor("#9e9e9e"), tcell.ColorBlack, tcell.GetColor("#2E7D32")
+
+	table := tview.NewTable()
+	row := 0
+	header := func(titles ...string) {
+		statsHeader(table, row, titles...)
+		row += 1
+	}
+
+	table.SetCell(row, 0, statsCell(fmt.Sprintf(" %s:%s", filepath.Base(c.filePath), lineRange(data.fileLine(0), data.fileLine(total-1))), grey, tview.AlignLeft))
+	row += 2
+
+	header(" author", "lines", "", "")
+	for _, st := range authorStatistics(data) {
+		table.SetCell(row, 0, statsCell(" ■ "+st.author.name, st.author.color, tview.AlignLeft))
+		table.SetCell(row, 1, statsCell(fmt.Sprint(st.lines), black, tview.AlignRight))
+		table.SetCell(row, 2, statsCell(percent(st.lines, total), black, tview.AlignRight))
+		row += 1
+	}
+
+	row += 1
+	header(" commit", "lines", "", "date")
+	for _, st := range commitStatistics(data) {
+		cm := st.commit
+		table.SetCell(row, 0, statsCell(" ■ "+cm.sha[:8]+" "+tview.Escape(cm.summary), cm.color, tview.AlignLeft).SetMaxWidth(50))
+		table.SetCell(row, 1, statsCell(fmt.Sprint(st.lines), black, tview.AlignRight))
+		table.SetCell(row, 2, statsCell(percent(st.lines, total), black, tview.AlignRight))
+		table.SetCell(row, 3, statsCell(cm.authorTime.Format("2006-01-02"), green, tview.AlignRight))
+		row += 1
+	}
+
+	table.
+		SetBorder(true).
+		SetTitle(" selection ").
+		SetTitleColor(tcell.ColorBlack.TrueColor()).
+		SetBorderColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			return event
+		}
+		c.closeModal("selection")
+		return nil
+	})
+
+	c.showModal("selection", table, 80, min(row+2, 30))
+}
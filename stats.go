@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// authorStats is an author's share of the lines of a blame.
+type authorStats struct {
+	author  *author
+	lines   int
+	commits int
+	// first and last are the times of the author's oldest and youngest
+	// commit with lines in the blame.
+	first time.Time
+	last  time.Time
+}
+
+// commitStats is a commit's share of the lines of a blame.
+type commitStats struct {
+	commit *commit
+	lines  int
+}
+
+// authorStatistics returns the authors of data's lines, most lines first.
+func authorStatistics(data *blameData) []authorStats {
+	byAuthor := map[identity]*authorStats{}
+	seen := map[*commit]bool{}
+	for _, cm := range data.lineCommits {
+		id := identity{name: cm.author.name, email: cm.author.email}
+		st, ok := byAuthor[id]
+		if !ok {
+			st = &authorStats{author: cm.author, first: cm.authorTime, last: cm.authorTime}
+			byAuthor[id] = st
+		}
+		st.lines += 1
+		if seen[cm] {
+			continue
+		}
+		seen[cm] = true
+		st.commits += 1
+		if cm.authorTime.Before(st.first) {
+			st.first = cm.authorTime
+		}
+		if cm.authorTime.After(st.last) {
+			st.last = cm.authorTime
+		}
+	}
+
+	res := []authorStats{}
+	for _, st := range byAuthor {
+		res = append(res, *st)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].lines != res[j].lines {
+			return res[i].lines > res[j].lines
+		}
+		return res[i].author.name < res[j].author.name
+	})
+	return res
+}
+
+// commitStatistics returns the commits of data's lines, most lines first.
+func commitStatistics(data *blameData) []commitStats {
+	byCommit := map[*commit]int{}
+	for _, cm := range data.lineCommits {
+		byCommit[cm] += 1
+	}
+
+	res := []commitStats{}
+	for cm, n := range byCommit {
+		res = append(res, commitStats{commit: cm, lines: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].lines != res[j].lines {
+			return res[i].lines > res[j].lines
+		}
+		return res[i].commit.authorTime.After(res[j].commit.authorTime)
+	})
+	return res
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(n)/float64(total))
+}
+
+// toggleStats swaps the log pane for the statistics pane and focuses it,
+// selecting a row there highlights the row's lines.
+func (c *container) toggleStats() {
+	c.showStats = !c.showStats
+	if !c.showStats {
+		c.highlighted = nil
+		c.flexMain.RemoveItem(c.statsView)
+		c.flexMain.AddItem(c.logView, 43, 4, true)
+		c.app.SetFocus(c.fileView)
+		c.render()
+		return
+	}
+
+	c.flexMain.RemoveItem(c.logView)
+	c.flexMain.AddItem(c.statsView, 64, 4, true)
+	c.renderStats()
+	c.app.SetFocus(c.statsView)
+}
+
+func statsHeader(table *tview.Table, row int, titles ...string) {
+	for i, t := range titles {
+		align := tview.AlignRight
+		if i == 0 {
+			align = tview.AlignLeft
+		}
+		table.SetCell(row, i, tview.NewTableCell(t).
+			SetAlign(align).
+			SetSelectable(false).
+			SetTextColor(tcell.GetColor("#9e9e9e").TrueColor()).
+			SetBackgroundColor(tcell.ColorWhite.TrueColor()))
+	}
+}
+
+func statsCell(text string, color tcell.Color, align int) *tview.TableCell {
+	return tview.NewTableCell(text).
+		SetAlign(align).
+		SetTextColor(color.TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+}
+
+// renderStats fills the statistics pane from the active tab's blame.
+func (c *container) renderStats() {
+	table := c.statsView
+	table.Clear()
+	c.highlighted = nil
+	if c.data == nil {
+		return
+	}
+
+	total := len(c.data.lines)
+	black := tcell.ColorBlack
+	green := tcell.GetColor("#2E7D32")
+	rowMatches := map[int]func(*commit) bool{}
+
+	row := 0
+	statsHeader(table, row, " author", "lines", "", "commits", "first", "last")
+	row += 1
+	for _, st := range authorStatistics(c.data) {
+		a := st.author
+		table.SetCell(row, 0, statsCell(" ■ "+a.name, a.color, tview.AlignLeft))
+		table.SetCell(row, 1, statsCell(fmt.Sprint(st.lines), black, tview.AlignRight))
+		table.SetCell(row, 2, statsCell(percent(st.lines, total), black, tview.AlignRight))
+		table.SetCell(row, 3, statsCell(fmt.Sprint(st.commits), black, tview.AlignRight))
+		table.SetCell(row, 4, statsCell(st.first.Format("2006-01-02"), green, tview.AlignRight))
+		table.SetCell(row, 5, statsCell(st.last.Format("2006-01-02"), green, tview.AlignRight))
+		rowMatches[row] = func(cm *commit) bool { return cm.author.name == a.name && cm.author.email == a.email }
+		row += 1
+	}
+
+	row += 1
+	statsHeader(table, row, " commit", "lines", "", "", "date", "")
+	row += 1
+	for _, st := range commitStatistics(c.data) {
+		cm := st.commit
+		table.SetCell(row, 0, statsCell(" ■ "+cm.sha[:8]+" "+cm.author.name, cm.color, tview.AlignLeft))
+		table.SetCell(row, 1, statsCell(fmt.Sprint(st.lines), black, tview.AlignRight))
+		table.SetCell(row, 2, statsCell(percent(st.lines, total), black, tview.AlignRight))
+		table.SetCell(row, 4, statsCell(cm.authorTime.Format("2006-01-02"), green, tview.AlignRight))
+		rowMatches[row] = func(o *commit) bool { return o == cm }
+		row += 1
+	}
+
+	table.SetSelectionChangedFunc(func(row, _ int) {
+		c.highlighted = rowMatches[row]
+		c.render()
+	})
+	table.Select(1, 0)
+	table.ScrollToBeginning()
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// tab is a file opened in gb, each keeping its own revision, cursor and
+// history.
+type tab struct {
+	filePath string
+	// startRev is the revision blamed when the tab is loaded, "" for the
+	// working tree, opts are the options of that blame.
+	startRev    string
+	opts        blameOptions
+	data        *blameData
+	lineCount   int
+	revListDesc []revision
+	currentLine int
+	// selecting is set while lines from anchor to currentLine are selected.
+	selecting bool
+	anchor    int
+
+	// history holds the positions to return to via back, most recent last.
+	history []position
+}
+
+// position is a revision and line of a file and how it's blamed.
+type position struct {
+	filePath string
+	rev      string
+	line     int
+	opts     blameOptions
+}
+
+// load fetches the revision list and initial blame of t in the background,
+// calling onErr if either fails.
+func (c *container) load(t *tab, onErr func(error)) {
+	revList, err := c.revList(t.filePath)
+	if err != nil {
+		onErr(fmt.Errorf("failed to get rev list of %s err=%w", t.filePath, err))
+		return
+	}
+
+	out, err := blame(t.filePath, t.startRev, t.opts)
+	if err != nil {
+		onErr(fmt.Errorf("failed to get initial blame output of %s err=%w", t.filePath, err))
+		return
+	}
+	c.chBlame <- blameResult{tab: t, filePath: t.filePath, revList: revList, data: out}
+}
+
+// newTab returns a tab for arg, a file path or rev:path. As with git, the
+// path of rev:path is relative to the repository root unless it starts with
+// ./ or ../. Paths that don't exist in the working tree are blamed at the
+// youngest revision of HEAD's history that has them.
+func newTab(arg string) (*tab, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return &tab{filePath: arg}, nil
+	}
+
+	if rev, p, ok := strings.Cut(arg, ":"); ok && rev != "" && p != "" {
+		fp := filepath.FromSlash(p)
+		if !filepath.IsAbs(fp) && !strings.HasPrefix(p, "./") && !strings.HasPrefix(p, "../") {
+			root, err := repoRoot(".")
+			if err != nil {
+				return nil, err
+			}
+			fp = filepath.Join(root, fp)
+		}
+
+		err := checkExists(fp, rev)
+		if err != nil {
+			return nil, err
+		}
+		return &tab{filePath: fp, startRev: rev}, nil
+	}
+
+	rev, err := lastRevision(arg)
+	if err != nil {
+		return nil, err
+	}
+	messages.add(levelInfo, "%s doesn't exist in the working tree, using %s", arg, rev[:8])
+	return &tab{filePath: arg, startRev: rev}, nil
+}
+
+// checkExists returns an error if filePath doesn't exist at rev.
+func checkExists(filePath, rev string) error {
+	cd, err := cmdDir(filePath)
+	if err != nil {
+		return err
+	}
+	path, err := repoPath(filePath)
+	if err != nil {
+		return err
+	}
+
+	_, err = runGit(cd, "cat-file", "-e", rev+":"+path)
+	if err != nil {
+		return fmt.Errorf("%s doesn't exist at %s", path, rev)
+	}
+	return nil
+}
+
+// lastRevision returns the youngest revision of HEAD's history that has
+// filePath, which is the parent of the revision that deleted it.
+func lastRevision(filePath string) (string, error) {
+	cd, err := cmdDir(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := runGit(cd, "rev-list", "-1", "HEAD", "--", filePath)
+	if err != nil {
+		return "", err
+	}
+	sha := strings.TrimSpace(string(buf))
+	if sha == "" {
+		return "", fmt.Errorf("%s isn't in the history of HEAD", filePath)
+	}
+
+	if checkExists(filePath, sha) == nil {
+		return sha, nil
+	}
+	buf, err = runGit(cd, "rev-parse", "--verify", "--quiet", sha+"^")
+	if err != nil {
+		return "", fmt.Errorf("%s has no parent", sha[:8])
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// openTab switches to the tab of filePath, opening a new one if there is none.
+func (c *container) openTab(filePath string) {
+	for _, t := range c.tabs {
+		if filepath.Clean(t.filePath) == filepath.Clean(filePath) {
+			c.switchTab(t)
+			return
+		}
+	}
+
+	t := &tab{filePath: filePath, opts: c.tab.opts}
+	c.tabs = append(c.tabs, t)
+	c.switchTab(t)
+
+	go c.load(t, func(err error) {
+		c.fail(err)
+		c.app.QueueUpdateDraw(func() { c.closeTab(t) })
+	})
+}
+
+func (c *container) switchTab(t *tab) {
+	c.searchMode = false
+	c.searchQuery = ""
+	c.readingSearchQuery = nil
+	c.readingLineNumber = nil
+
+	c.tab = t
+	c.showTab()
+}
+
+// nextTab switches to the tab delta positions after the active one, wrapping
+// around at either end.
+func (c *container) nextTab(delta int) {
+	if len(c.tabs) < 2 {
+		return
+	}
+
+	for i, t := range c.tabs {
+		if t == c.tab {
+			next := (i + delta + len(c.tabs)) % len(c.tabs)
+			c.switchTab(c.tabs[next])
+			return
+		}
+	}
+}
+
+func (c *container) closeTab(t *tab) {
+	if len(c.tabs) < 2 {
+		c.warn("can't close the last tab")
+		return
+	}
+
+	for i, o := range c.tabs {
+		if o != t {
+			continue
+		}
+
+		c.tabs = append(c.tabs[:i], c.tabs[i+1:]...)
+		if t == c.tab {
+			c.switchTab(c.tabs[min(i, len(c.tabs)-1)])
+		} else {
+			c.renderTabbar()
+		}
+		return
+	}
+}
+
+func (c *container) renderTabbar() {
+	if len(c.tabs) < 2 {
+		c.flexRoot.ResizeItem(c.tabbar, 0, 0)
+		return
+	}
+
+	var b strings.Builder
+	for i, t := range c.tabs {
+		label := fmt.Sprintf(" %d %s ", i+1, tview.Escape(filepath.Base(t.filePath)))
+		if t == c.tab {
+			b.WriteString("[#000000:#e8ecf0]" + label + "[#9e9e9e:#ffffff]")
+		} else {
+			b.WriteString(label)
+		}
+		b.WriteString(" ")
+	}
+	c.tabbar.SetText(b.String())
+	c.flexRoot.ResizeItem(c.tabbar, 1, 0)
+}
+
+// navigate blames rev and moves to line, remembering the current position to
+// return to via back.
+func (c *container) navigate(rev string, line int) {
+	c.navigateTo(position{filePath: c.filePath, rev: rev, line: line, opts: c.blameOptions()})
+}
+
+// navigateTo is navigate for a position that may be in another file, which
+// replaces the active tab's file.
+func (c *container) navigateTo(pos position) {
+	if c.data != nil {
+		c.history = append(c.history, position{filePath: c.filePath, rev: c.data.rev, line: c.currentLine, opts: c.data.opts})
+	}
+	c.blamePosition(pos)
+}
+
+func (c *container) back() {
+	if len(c.history) == 0 {
+		c.warn("no previous position")
+		return
+	}
+
+	pos := c.history[len(c.history)-1]
+	c.history = c.history[:len(c.history)-1]
+	c.blamePosition(pos)
+}
+
+// followOrigin navigates to where the current line came from in its commit,
+// which is in another file for code that was moved or copied.
+func (c *container) followOrigin() {
+	cm := c.lineCommit()
+	if cm == nil {
+		return
+	}
+	if cm.sha == uncommittedSHA {
+		c.warn("line is not committed yet")
+		return
+	}
+
+	o := c.data.origins[c.currentLine]
+	fp, err := repoFile(c.filePath, o.path)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get repository root err=%w", err))
+		return
+	}
+
+	if o.path != c.data.path {
+		c.info(fmt.Sprintf("[#4CAF50]%s[#000000]: from %s:%d", cm.sha[:8], tview.Escape(o.path), o.line+1))
+	}
+	opts := c.data.opts
+	opts.reverse = false
+	c.navigateTo(position{filePath: fp, rev: cm.sha, line: o.line, opts: opts})
+}
+
+// navigateRevision navigates to r, blaming the file under its path at r.
+func (c *container) navigateRevision(r revision) {
+	fp, err := repoFile(c.filePath, r.path)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to get repository root err=%w", err))
+		return
+	}
+	c.navigateTo(position{filePath: fp, rev: r.sha, opts: c.blameOptions()})
+}
+
+// trackedFiles returns the files tracked in the repository of filePath as
+// paths that can be opened, i.e. relative to the working directory unless
+// filePath is absolute.
+func trackedFiles(filePath string) ([]string, error) {
+	cd, err := cmdDir(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := repoRoot(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := runGit(cd, "ls-files", "--full-name", "--", ":/")
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for _, f := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+		if f == "" {
+			continue
+		}
+		res = append(res, filepath.Join(root, filepath.FromSlash(f)))
+	}
+	return res, nil
+}
+
+// fuzzyMatch reports whether the runes of query appear in candidate in order,
+// ignoring case. The score favours consecutive matches and matches at the
+// start of path segments or words. The indexes of the matched runes are
+// returned for highlighting.
+func fuzzyMatch(query, candidate string) (int, []int, bool) {
+	q := []rune(strings.ToLower(query))
+	runes := []rune(candidate)
+	matched := make([]int, 0, len(q))
+
+	score := 0
+	qi := 0
+	for i := 0; i < len(runes) && qi < len(q); i++ {
+		if unicode.ToLower(runes[i]) != q[qi] {
+			continue
+		}
+
+		score += 1
+		if len(matched) > 0 && matched[len(matched)-1] == i-1 {
+			score += 5
+		}
+		if i == 0 || strings.ContainsRune("/._- ", runes[i-1]) {
+			score += 3
+		}
+		matched = append(matched, i)
+		qi += 1
+	}
+
+	if qi < len(q) {
+		return 0, nil, false
+	}
+	return score, matched, true
+}
+
+type fuzzyResult struct {
+	candidate string
+	score     int
+	matched   []int
+}
+
+// fuzzyFilter returns up to limit candidates matching query, best first.
+func fuzzyFilter(query string, candidates []string, limit int) []fuzzyResult {
+	res := []fuzzyResult{}
+	for _, cand := range candidates {
+		score, matched, ok := fuzzyMatch(query, cand)
+		if ok {
+			res = append(res, fuzzyResult{candidate: cand, score: score, matched: matched})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].score != res[j].score {
+			return res[i].score > res[j].score
+		}
+		return len(res[i].candidate) < len(res[j].candidate)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// highlightMatch marks the matched runes of r in green.
+func highlightMatch(r fuzzyResult) string {
+	var b strings.Builder
+	next := 0
+	for i, rn := range []rune(r.candidate) {
+		str := tview.Escape(string(rn))
+		if next < len(r.matched) && r.matched[next] == i {
+			str = "[#4CAF50]" + str + "[#000000]"
+			next += 1
+		}
+		b.WriteString(str)
+	}
+	return b.String()
+}
+
+// chooseFile opens a tracked file of the repository in a new tab via a fuzzy
+// file picker.
+func (c *container) chooseFile() {
+	files, err := trackedFiles(c.filePath)
+	if err != nil {
+		c.fail(fmt.Errorf("failed to list tracked files err=%w", err))
+		return
+	}
+
+	list := newList("").ShowSecondaryText(false)
+	list.SetBorder(false)
+
+	input := tview.NewInputField().
+		SetLabel("open: ").
+		SetLabelColor(tcell.GetColor("#4CAF50").TrueColor()).
+		SetFieldTextColor(tcell.ColorBlack.TrueColor()).
+		SetFieldBackgroundColor(tcell.GetColor("#e8ecf0").TrueColor())
+	input.SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	layout.
+		SetBorder(true).
+		SetTitle(" open file ").
+		SetTitleColor(tcell.ColorBlack.TrueColor()).
+		SetBorderColor(tcell.GetColor("#9e9e9e").TrueColor()).
+		SetBackgroundColor(tcell.ColorWhite.TrueColor())
+
+	var matches []fuzzyResult
+	update := func(query string) {
+		matches = fuzzyFilter(query, files, 200)
+		list.Clear()
+		for _, m := range matches {
+			list.AddItem(highlightMatch(m), "", 0, nil)
+		}
+	}
+	update("")
+
+	input.SetChangedFunc(update)
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyDown:
+			list.SetCurrentItem((list.GetCurrentItem() + 1) % max(1, list.GetItemCount()))
+			return nil
+		case tcell.KeyUp:
+			list.SetCurrentItem(max(0, list.GetCurrentItem()-1))
+			return nil
+		case tcell.KeyEnter:
+			if len(matches) == 0 {
+				return nil
+			}
+			c.closeModal("file")
+			c.openTab(matches[list.GetCurrentItem()].candidate)
+			return nil
+		case tcell.KeyEscape:
+			c.closeModal("file")
+			return nil
+		}
+		return event
+	})
+
+	c.showModal("file", layout, 80, 20)
+}